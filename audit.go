@@ -0,0 +1,227 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// AuditEvent is the type of the audited store mutation
+type AuditEvent string
+
+// Audited store mutations
+const (
+	AuditTokenCreated = AuditEvent("token_created")
+	AuditTokenRemoved = AuditEvent("token_removed")
+	AuditTokenExpired = AuditEvent("token_expired")
+
+	AuditClientCreated           = AuditEvent("client_created")
+	AuditClientUpdated           = AuditEvent("client_updated")
+	AuditClientRemoved           = AuditEvent("client_removed")
+	AuditClientDisabled          = AuditEvent("client_disabled")
+	AuditClientEnabled           = AuditEvent("client_enabled")
+	AuditClientExpirationChanged = AuditEvent("client_expiration_changed")
+	AuditClientSecretAdded       = AuditEvent("client_secret_added")
+	AuditClientSecretRevoked     = AuditEvent("client_secret_revoked")
+)
+
+// DefaultAuditTableName is the default audit log table name shared by the stores
+const DefaultAuditTableName = "oauth2_audit"
+
+// gcActor is the audit record actor of the mutations made by garbage collection
+const gcActor = "gc"
+
+// AuditRecord audit log data item. Token hash is SHA-256 hex of the credential the token was created or removed
+// with: access token, or refresh token or authorization code if there is no access token in the token information.
+// Token hash is empty for the tokens removed in bulk, e.g. by garbage collection, token id links them to the
+// creation record.
+type AuditRecord struct {
+	ID        int64             `db:"id" json:"id"`
+	CreatedAt time.Time         `db:"created_at" json:"created_at"`
+	Event     AuditEvent        `db:"event" json:"event"`
+	TenantID  string            `db:"tenant_id" json:"tenant_id"`
+	ClientID  string            `db:"client_id" json:"client_id"`
+	UserID    string            `db:"user_id" json:"user_id"`
+	TokenID   *int64            `db:"token_id" json:"token_id"`
+	TokenHash string            `db:"token_hash" json:"token_hash"`
+	Scope     string            `db:"scope" json:"scope"`
+	Actor     string            `db:"actor" json:"actor"`
+	Reason    string            `db:"reason" json:"reason"`
+	Metadata  map[string]string `db:"metadata" json:"metadata"`
+
+	// Seq is the record position in the hash chain, nil until the record is sealed
+	Seq      *int64 `db:"seq" json:"seq"`
+	PrevHash string `db:"prev_hash" json:"prev_hash"`
+	Hash     string `db:"hash" json:"hash"`
+}
+
+// AuditInfo is the audit details of the store mutation made with the context
+type AuditInfo struct {
+	// Actor is the identity of the mutation initiator, e.g. user id or admin name
+	Actor string
+	// Reason is the free form mutation reason
+	Reason string
+	// Metadata is the request metadata, e.g. remote address, user agent or request id
+	Metadata map[string]string
+}
+
+type auditInfoCtxKey struct{}
+
+// WithAuditInfo returns context with audit details of the store mutations made with it
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoCtxKey{}, info)
+}
+
+// AuditInfoFromContext returns audit details set to the context with WithAuditInfo
+func AuditInfoFromContext(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoCtxKey{}).(AuditInfo)
+	return info
+}
+
+// tokenAuditColumns are the token table columns of the audit records
+const tokenAuditColumns = `tenant_id, client_id, user_id, id AS token_id`
+
+// clientAuditColumns are the client table columns of the audit records
+const clientAuditColumns = `tenant_id, id AS client_id, user_id, NULL::bigint AS token_id`
+
+// auditEntry is the audited mutation, token hash and scope are set for the tokens as they are not stored
+// in the token table. With rowTokenHash set token hash and scope are taken from the source rows token_hash and scope
+// columns instead. Non-empty actor and reason take precedence over the ones set to the context with WithAuditInfo.
+type auditEntry struct {
+	event        AuditEvent
+	tokenHash    string
+	scope        string
+	rowTokenHash bool
+	actor        string
+	reason       string
+}
+
+// tokenHash returns SHA-256 hex of the token information credential
+func tokenHash(info oauth2.TokenInfo) string {
+	for _, credential := range []string{info.GetAccess(), info.GetRefresh(), info.GetCode()} {
+		if credential != "" {
+			return hashSecret(credential)
+		}
+	}
+	return ""
+}
+
+// auditLog writes audit records of the store mutations
+type auditLog struct {
+	enabled   bool
+	tableName string
+}
+
+func initAuditTable(ctx context.Context, adapter pgAdapter.Adapter, tableName string) error {
+	return adapter.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id         BIGSERIAL   NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	event      TEXT        NOT NULL,
+	tenant_id  TEXT        NOT NULL,
+	client_id  TEXT        NOT NULL,
+	user_id    TEXT        NOT NULL,
+	token_id   BIGINT,
+	token_hash TEXT        NOT NULL,
+	scope      TEXT        NOT NULL,
+	actor      TEXT        NOT NULL,
+	reason     TEXT        NOT NULL,
+	metadata   JSONB       NOT NULL,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_%[1]s_created_at ON %[1]s (created_at);
+CREATE INDEX IF NOT EXISTS idx_%[1]s_user_id ON %[1]s (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_%[1]s_client_id ON %[1]s (client_id, created_at);
+
+-- records are chained with SHA-256 hashes when they are sealed by audit store
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS hash TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_seq ON %[1]s (seq);
+
+-- audit log is append-only, sealing not yet sealed record is the only allowed change
+CREATE OR REPLACE FUNCTION %[1]s_append_only() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'UPDATE' AND OLD.hash IS NULL
+		AND to_jsonb(OLD) - 'seq' - 'prev_hash' - 'hash' = to_jsonb(NEW) - 'seq' - 'prev_hash' - 'hash' THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'audit log %[1]s is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = '%[1]s_append_only' AND tgrelid = '%[1]s'::regclass) THEN
+		CREATE TRIGGER %[1]s_append_only BEFORE UPDATE OR DELETE ON %[1]s
+		FOR EACH ROW EXECUTE PROCEDURE %[1]s_append_only();
+	END IF;
+END $$;
+
+COMMENT ON TABLE %[1]s IS '%[2]s';
+`, tableName, auditTableSchema.comment()))
+}
+
+// wrap turns data-modifying query into the statement that also writes audit record for every modified row,
+// returning is the audit columns list for the query RETURNING clause. Audit records are inserted by the same
+// statement, so they are committed or rolled back together with the change. Non-empty result is the final
+// SELECT from the "changed" rows that makes the statement return them.
+func (a auditLog) wrap(ctx context.Context, entry auditEntry, query, returning, result string, args []interface{}) (string, []interface{}, error) {
+	if !a.enabled {
+		if result == "" {
+			return query, args, nil
+		}
+		return fmt.Sprintf("WITH changed AS (%s RETURNING %s)\n%s", query, returning, result), args, nil
+	}
+
+	insert, args, err := a.insert(ctx, entry, "changed", args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if result == "" {
+		return fmt.Sprintf("WITH changed AS (%s RETURNING %s)\n%s", query, returning, insert), args, nil
+	}
+	return fmt.Sprintf("WITH changed AS (%s RETURNING %s), audited AS (%s)\n%s", query, returning, insert, result), args, nil
+}
+
+// insert returns the statement that writes audit record for every row of the source CTE having the audit columns,
+// statement parameters are appended to the args
+func (a auditLog) insert(ctx context.Context, entry auditEntry, source string, args []interface{}) (string, []interface{}, error) {
+	info := AuditInfoFromContext(ctx)
+	metadata, err := json.Marshal(info.Metadata)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Metadata == nil {
+		metadata = []byte("{}")
+	}
+	if entry.actor != "" {
+		info.Actor = entry.actor
+	}
+	if entry.reason != "" {
+		info.Reason = entry.reason
+	}
+
+	args = append(args, time.Now(), string(entry.event))
+	n := len(args)
+	tokenColumns := "token_hash, scope"
+	if !entry.rowTokenHash {
+		args = append(args, entry.tokenHash, entry.scope)
+		tokenColumns = fmt.Sprintf("$%d::text, $%d::text", n+1, n+2)
+	}
+	args = append(args, info.Actor, info.Reason, string(metadata))
+	m := len(args)
+
+	query := fmt.Sprintf(`INSERT INTO %s (created_at, event, tenant_id, client_id, user_id, token_id, token_hash, scope, actor, reason, metadata)
+SELECT $%d::timestamptz, $%d::text, tenant_id, client_id, user_id, token_id, %s, $%d::text, $%d::text, $%d::jsonb FROM %s`,
+		a.tableName, n-1, n, tokenColumns, m-2, m-1, m, source)
+
+	return query, args, nil
+}
@@ -0,0 +1,212 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// auditChainBatchSize is the number of audit records sealed or verified at once
+const auditChainBatchSize = 1000
+
+// AuditChainReport is the audit records hash chain verification result
+type AuditChainReport struct {
+	// Verified is the number of verified sealed records
+	Verified int64
+	// Unsealed is the number of records in the verified time range that are not sealed yet
+	Unsealed int64
+	// Broken is the first broken chain link, nil if the chain is intact
+	Broken *AuditChainBreak
+}
+
+// AuditChainBreak is the broken audit records hash chain link
+type AuditChainBreak struct {
+	Seq    int64
+	ID     int64
+	Reason string
+}
+
+func (b *AuditChainBreak) String() string {
+	return fmt.Sprintf("seq %d (id %d): %s", b.Seq, b.ID, b.Reason)
+}
+
+// chainHash returns SHA-256 hex of the previous record hash followed by the record content,
+// record content fields are separated with the unit separator character
+func (r *AuditRecord) chainHash(seq int64, prevHash string) string {
+	metadata, _ := json.Marshal(r.Metadata)
+
+	var tokenID string
+	if r.TokenID != nil {
+		tokenID = strconv.FormatInt(*r.TokenID, 10)
+	}
+
+	content := strings.Join([]string{
+		strconv.FormatInt(seq, 10),
+		strconv.FormatInt(r.ID, 10),
+		r.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		string(r.Event),
+		r.TenantID,
+		r.ClientID,
+		r.UserID,
+		tokenID,
+		r.TokenHash,
+		r.Scope,
+		r.Actor,
+		r.Reason,
+		string(metadata),
+	}, "\x1f")
+
+	sum := sha256.Sum256([]byte(prevHash + content))
+	return hex.EncodeToString(sum[:])
+}
+
+type auditChainHead struct {
+	Seq  int64  `db:"seq"`
+	Hash string `db:"hash"`
+}
+
+// Seal appends not yet sealed audit records to the hash chain in the order they were written,
+// returns the number of sealed records. Concurrent sealing fails on the unique chain position constraint,
+// so the chain never forks.
+func (s *AuditStore) Seal(ctx context.Context) (int, error) {
+	var sealed int
+	for {
+		var batch int
+		err := runInTx(ctx, s.adapter, func(ctx context.Context, tx pgAdapter.Adapter) error {
+			var err error
+			batch, err = s.sealBatch(ctx, tx)
+			return err
+		})
+		sealed += batch
+		if err != nil || batch < auditChainBatchSize {
+			return sealed, err
+		}
+	}
+}
+
+func (s *AuditStore) sealBatch(ctx context.Context, tx pgAdapter.Adapter) (int, error) {
+	var head auditChainHead
+	err := tx.SelectOne(ctx, &head, fmt.Sprintf("SELECT seq, hash FROM %s WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1", s.tableName))
+	if err != nil && err != pgAdapter.ErrNoRows {
+		return 0, err
+	}
+
+	var records []*AuditRecord
+	if err := selectAll(ctx, tx, &records, fmt.Sprintf("SELECT * FROM %s WHERE hash IS NULL ORDER BY id LIMIT %d", s.tableName, auditChainBatchSize)); err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	type link struct {
+		ID       int64  `json:"id"`
+		Seq      int64  `json:"seq"`
+		PrevHash string `json:"prev_hash"`
+		Hash     string `json:"hash"`
+	}
+	links := make([]link, 0, len(records))
+	for _, r := range records {
+		l := link{ID: r.ID, Seq: head.Seq + 1, PrevHash: head.Hash}
+		l.Hash = r.chainHash(l.Seq, l.PrevHash)
+		links = append(links, l)
+		head = auditChainHead{Seq: l.Seq, Hash: l.Hash}
+	}
+
+	buf, err := json.Marshal(links)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Exec(ctx, fmt.Sprintf(`
+UPDATE %s a SET seq = l.seq, prev_hash = l.prev_hash, hash = l.hash
+FROM json_to_recordset($1::json) AS l(id BIGINT, seq BIGINT, prev_hash TEXT, hash TEXT)
+WHERE a.id = l.id AND a.hash IS NULL`, s.tableName), string(buf)); err != nil {
+		return 0, err
+	}
+
+	return len(records), nil
+}
+
+// VerifyAuditChain checks hash chain links of the sealed audit records written within the time range
+// and reports the first broken one. Zero from or to means the range is not bounded on that side.
+func (s *AuditStore) VerifyAuditChain(ctx context.Context, from, to time.Time) (*AuditChainReport, error) {
+	if to.IsZero() {
+		to = time.Now().Add(time.Hour)
+	}
+
+	var bounds struct {
+		MinSeq   int64 `db:"min_seq"`
+		MaxSeq   int64 `db:"max_seq"`
+		Unsealed int64 `db:"unsealed"`
+	}
+	if err := s.db(ctx).SelectOne(ctx, &bounds, fmt.Sprintf(`
+SELECT COALESCE(min(seq), 0) AS min_seq, COALESCE(max(seq), 0) AS max_seq, count(*) FILTER (WHERE seq IS NULL) AS unsealed
+FROM %s WHERE created_at >= $1 AND created_at < $2`, s.tableName), from, to); err != nil {
+		return nil, err
+	}
+
+	// chain positions start with 1, zero bounds mean there are no sealed records in the range
+	report := &AuditChainReport{Unsealed: bounds.Unsealed}
+	if bounds.MinSeq == 0 {
+		return report, nil
+	}
+
+	expectedSeq, prevHash := bounds.MinSeq, ""
+	if expectedSeq > 1 {
+		var prev auditChainHead
+		err := s.db(ctx).SelectOne(ctx, &prev, fmt.Sprintf("SELECT seq, hash FROM %s WHERE seq = $1", s.tableName), expectedSeq-1)
+		if err == pgAdapter.ErrNoRows {
+			report.Broken = &AuditChainBreak{Seq: expectedSeq - 1, Reason: "record is missing"}
+			return report, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		prevHash = prev.Hash
+	}
+
+	for expectedSeq <= bounds.MaxSeq {
+		var records []*AuditRecord
+		if err := selectAll(
+			ctx,
+			s.db(ctx),
+			&records,
+			fmt.Sprintf("SELECT * FROM %s WHERE seq >= $1 AND seq <= $2 ORDER BY seq LIMIT %d", s.tableName, auditChainBatchSize),
+			expectedSeq,
+			bounds.MaxSeq,
+		); err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			report.Broken = &AuditChainBreak{Seq: expectedSeq, Reason: "record is missing"}
+			return report, nil
+		}
+
+		for _, r := range records {
+			switch {
+			case *r.Seq != expectedSeq:
+				report.Broken = &AuditChainBreak{Seq: expectedSeq, Reason: "record is missing"}
+			case r.PrevHash != prevHash:
+				report.Broken = &AuditChainBreak{Seq: *r.Seq, ID: r.ID, Reason: "previous record hash does not match"}
+			case r.Hash != r.chainHash(*r.Seq, r.PrevHash):
+				report.Broken = &AuditChainBreak{Seq: *r.Seq, ID: r.ID, Reason: "record hash does not match its content"}
+			}
+			if report.Broken != nil {
+				return report, nil
+			}
+
+			report.Verified++
+			expectedSeq, prevHash = *r.Seq+1, r.Hash
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,118 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestAuditRecord_chainHash(t *testing.T) {
+	tokenID := int64(42)
+	r := &AuditRecord{
+		ID:        1,
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		Event:     AuditTokenCreated,
+		ClientID:  "client",
+		TokenID:   &tokenID,
+		Metadata:  map[string]string{"b": "2", "a": "1"},
+	}
+
+	hash := r.chainHash(1, "")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, r.chainHash(1, ""))
+
+	// the same instant in another location has the same content
+	r.CreatedAt = r.CreatedAt.In(time.FixedZone("UTC+3", 3*60*60))
+	assert.Equal(t, hash, r.chainHash(1, ""))
+
+	assert.NotEqual(t, hash, r.chainHash(2, ""))
+	assert.NotEqual(t, hash, r.chainHash(1, "prev"))
+
+	r.Reason = "edited"
+	assert.NotEqual(t, hash, r.chainHash(1, ""))
+}
+
+func TestAuditStore_VerifyAuditChain(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	auditTableName := fmt.Sprintf("audit_%d", time.Now().UnixNano())
+
+	clientStore, err := NewClientStore(
+		adapter,
+		WithClientStoreTableName(generateClientTableName()),
+		WithClientStoreAudit(),
+		WithClientStoreAuditTableName(auditTableName),
+	)
+	require.NoError(t, err)
+
+	auditStore, err := NewAuditStore(adapter, WithAuditStoreTableName(auditTableName))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, auditStore.Close())
+	}()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, clientStore.Create(ctx, &models.Client{ID: fmt.Sprintf("id %d %s", i, time.Now().String())}))
+	}
+
+	report, err := auditStore.VerifyAuditChain(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, &AuditChainReport{Unsealed: 3}, report)
+
+	sealed, err := auditStore.Seal(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sealed)
+
+	require.NoError(t, clientStore.Create(ctx, &models.Client{ID: fmt.Sprintf("id 3 %s", time.Now().String())}))
+	sealed, err = auditStore.Seal(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sealed)
+
+	report, err = auditStore.VerifyAuditChain(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, &AuditChainReport{Verified: 4}, report)
+
+	records, err := auditStore.Query(ctx, AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, "", records[0].PrevHash)
+	assert.Equal(t, records[2].Hash, records[3].PrevHash)
+
+	// range verification checks the link to the record before the range
+	report, err = auditStore.VerifyAuditChain(ctx, records[2].CreatedAt, time.Time{})
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.True(t, report.Verified >= 2)
+
+	// sealed records can not be changed
+	assert.Error(t, adapter.Exec(ctx, fmt.Sprintf("UPDATE %s SET hash = NULL WHERE id = $1", auditTableName), records[1].ID))
+
+	require.NoError(t, adapter.Exec(ctx, fmt.Sprintf("ALTER TABLE %[1]s DISABLE TRIGGER %[1]s_append_only", auditTableName)))
+	require.NoError(t, adapter.Exec(ctx, fmt.Sprintf("UPDATE %s SET reason = 'tampered' WHERE id = $1", auditTableName), records[1].ID))
+
+	report, err = auditStore.VerifyAuditChain(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, *records[1].Seq, report.Broken.Seq)
+	assert.Equal(t, int64(1), report.Verified)
+
+	require.NoError(t, adapter.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", auditTableName), records[1].ID))
+
+	report, err = auditStore.VerifyAuditChain(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, *records[1].Seq, report.Broken.Seq)
+	assert.Equal(t, "record is missing", report.Broken.Reason)
+}
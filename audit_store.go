@@ -0,0 +1,129 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// AuditStore PostgreSQL audit log reader, audit records are written by the token and client stores
+// with the audit enabled. Audit store seals written records into tamper-evident hash chain.
+type AuditStore struct {
+	adapter   pgAdapter.Adapter
+	tableName string
+	logger    Logger
+
+	sealInterval time.Duration
+	ticker       *time.Ticker
+
+	initTableDisabled bool
+}
+
+// AuditQuery is the audit log query filter, empty values are not filtered by
+type AuditQuery struct {
+	UserID   string
+	ClientID string
+	TenantID string
+	// From is the inclusive lower bound of the record creation time
+	From time.Time
+	// To is the exclusive upper bound of the record creation time
+	To time.Time
+	// Limit is the maximum number of the records to return, zero means no limit
+	Limit int
+}
+
+// NewAuditStore creates PostgreSQL store instance
+func NewAuditStore(adapter pgAdapter.Adapter, options ...AuditStoreOption) (*AuditStore, error) {
+	store := &AuditStore{
+		adapter:   adapter,
+		tableName: DefaultAuditTableName,
+		logger:    log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+	}
+
+	for _, o := range options {
+		o(store)
+	}
+
+	var err error
+	if !store.initTableDisabled {
+		err = initAuditTable(context.Background(), store.adapter, store.tableName)
+	}
+
+	if err != nil {
+		return store, err
+	}
+
+	if store.sealInterval > 0 {
+		store.ticker = time.NewTicker(store.sealInterval)
+		go store.seal()
+	}
+
+	return store, err
+}
+
+// Close closes the store
+func (s *AuditStore) Close() error {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	return nil
+}
+
+// db returns the transaction adapter bound to the context or the store adapter
+func (s *AuditStore) db(ctx context.Context) pgAdapter.Adapter {
+	return resolveAdapter(ctx, s.adapter)
+}
+
+func (s *AuditStore) seal() {
+	for range s.ticker.C {
+		if _, err := s.Seal(context.Background()); err != nil {
+			s.logger.Printf("Error while sealing audit records: %+v", err)
+		}
+	}
+}
+
+// Query returns audit records matching the filter ordered by the time they were written
+func (s *AuditStore) Query(ctx context.Context, q AuditQuery) ([]*AuditRecord, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q.UserID != "" {
+		where("user_id = $%d", q.UserID)
+	}
+	if q.ClientID != "" {
+		where("client_id = $%d", q.ClientID)
+	}
+	if q.TenantID != "" {
+		where("tenant_id = $%d", q.TenantID)
+	}
+	if !q.From.IsZero() {
+		where("created_at >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		where("created_at < $%d", q.To)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", s.tableName)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	var items []*AuditRecord
+	err := selectAll(ctx, s.db(ctx), &items, query, args...)
+	return items, err
+}
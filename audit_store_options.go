@@ -0,0 +1,35 @@
+package pg
+
+import "time"
+
+// AuditStoreOption is the configuration options type for audit store
+type AuditStoreOption func(s *AuditStore)
+
+// WithAuditStoreTableName returns option that sets audit store table name
+func WithAuditStoreTableName(tableName string) AuditStoreOption {
+	return func(s *AuditStore) {
+		s.tableName = tableName
+	}
+}
+
+// WithAuditStoreInitTableDisabled returns option that disables table creation on audit store instantiation
+func WithAuditStoreInitTableDisabled() AuditStoreOption {
+	return func(s *AuditStore) {
+		s.initTableDisabled = true
+	}
+}
+
+// WithAuditStoreLogger returns option that sets audit store logger implementation
+func WithAuditStoreLogger(logger Logger) AuditStoreOption {
+	return func(s *AuditStore) {
+		s.logger = logger
+	}
+}
+
+// WithAuditStoreSealInterval returns option that enables periodic sealing of the written audit records
+// into the hash chain, see AuditStore.Seal
+func WithAuditStoreSealInterval(sealInterval time.Duration) AuditStoreOption {
+	return func(s *AuditStore) {
+		s.sealInterval = sealInterval
+	}
+}
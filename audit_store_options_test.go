@@ -0,0 +1,36 @@
+package pg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAuditStoreTableName(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewAuditStore(nil, WithAuditStoreTableName(randomName), WithAuditStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomName, store.tableName)
+}
+
+func TestWithTokenStoreAudit(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreAudit(), WithTokenStoreAuditTableName("audit"), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, auditLog{enabled: true, tableName: "audit"}, store.audit)
+}
+
+func TestWithClientStoreAudit(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreAudit(), WithClientStoreAuditTableName("audit"), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, auditLog{enabled: true, tableName: "audit"}, store.audit)
+}
+
+func TestWithAuditStoreSealInterval(t *testing.T) {
+	store, err := NewAuditStore(nil, WithAuditStoreSealInterval(time.Second), WithAuditStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, store.sealInterval)
+	assert.NoError(t, store.Close())
+}
@@ -0,0 +1,114 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestAuditStore(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	auditTableName := fmt.Sprintf("audit_%d", time.Now().UnixNano())
+	tokenTableName := generateTokenTableName()
+
+	tokenStore, err := NewTokenStore(
+		adapter,
+		WithTokenStoreTableName(tokenTableName),
+		WithTokenStoreGCDisabled(),
+		WithTokenStoreAudit(),
+		WithTokenStoreAuditTableName(auditTableName),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	clientStore, err := NewClientStore(
+		adapter,
+		WithClientStoreTableName(generateClientTableName()),
+		WithClientStoreTokenTableName(tokenTableName),
+		WithClientStoreAudit(),
+		WithClientStoreAuditTableName(auditTableName),
+	)
+	require.NoError(t, err)
+
+	auditStore, err := NewAuditStore(adapter, WithAuditStoreTableName(auditTableName))
+	require.NoError(t, err)
+
+	ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "admin", Metadata: map[string]string{"ip": "127.0.0.1"}})
+	started := time.Now()
+
+	client := &models.Client{ID: fmt.Sprintf("id %s", time.Now().String()), Secret: "secret", UserID: "owner"}
+	require.NoError(t, clientStore.Create(ctx, client))
+
+	for i := 0; i < 2; i++ {
+		token := models.NewToken()
+		token.SetClientID(client.ID)
+		token.SetUserID("user")
+		token.SetScope("read")
+		token.SetAccess(fmt.Sprintf("access %d %s", i, time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Minute)
+		require.NoError(t, tokenStore.Create(ctx, token))
+
+		if i == 0 {
+			require.NoError(t, tokenStore.RemoveByAccess(ctx, token.GetAccess()))
+		}
+	}
+
+	require.NoError(t, clientStore.Disable(ctx, client.ID, "compromised", true))
+
+	records, err := auditStore.Query(context.Background(), AuditQuery{ClientID: client.ID})
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+
+	events := make([]AuditEvent, 0, len(records))
+	for _, r := range records {
+		events = append(events, r.Event)
+	}
+	assert.Equal(t, []AuditEvent{AuditClientCreated, AuditTokenCreated, AuditTokenRemoved, AuditTokenCreated}, events[:4])
+	// client disabling and tokens revocation records are written by the same statement in no particular order
+	assert.ElementsMatch(t, []AuditEvent{AuditClientDisabled, AuditTokenRemoved}, events[4:])
+
+	assert.Equal(t, "owner", records[0].UserID)
+	assert.Equal(t, "", records[0].Actor)
+
+	assert.Equal(t, "user", records[1].UserID)
+	assert.Equal(t, "read", records[1].Scope)
+	assert.Equal(t, "admin", records[1].Actor)
+	assert.Equal(t, map[string]string{"ip": "127.0.0.1"}, records[1].Metadata)
+	require.NotNil(t, records[1].TokenID)
+	assert.Len(t, records[1].TokenHash, 64)
+	assert.Equal(t, records[1].TokenHash, records[2].TokenHash)
+	assert.Equal(t, records[1].TokenID, records[2].TokenID)
+
+	for _, r := range records[4:] {
+		assert.Equal(t, "compromised", r.Reason)
+		if r.Event == AuditTokenRemoved {
+			assert.Equal(t, records[3].TokenID, r.TokenID)
+		}
+	}
+
+	records, err = auditStore.Query(context.Background(), AuditQuery{UserID: "user", From: started, Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = auditStore.Query(context.Background(), AuditQuery{ClientID: client.ID, To: started})
+	require.NoError(t, err)
+	assert.Len(t, records, 0)
+
+	// audit log is append-only
+	assert.Error(t, adapter.Exec(context.Background(), fmt.Sprintf("DELETE FROM %s", auditTableName)))
+}
@@ -0,0 +1,44 @@
+package pg
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog_wrap(t *testing.T) {
+	ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "admin", Reason: "test", Metadata: map[string]string{"ip": "127.0.0.1"}})
+
+	query, args, err := auditLog{}.wrap(ctx, auditEntry{event: AuditTokenRemoved}, "DELETE FROM t WHERE id = $1", tokenAuditColumns, "", []interface{}{1})
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM t WHERE id = $1", query)
+	assert.Equal(t, []interface{}{1}, args)
+
+	query, args, err = auditLog{}.wrap(ctx, auditEntry{event: AuditClientEnabled}, "UPDATE c SET x = 1 WHERE id = $1", clientAuditColumns, "SELECT client_id FROM changed", []interface{}{1})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(query, "WITH changed AS (UPDATE c SET x = 1 WHERE id = $1 RETURNING "))
+	assert.True(t, strings.HasSuffix(query, "\nSELECT client_id FROM changed"))
+	assert.Equal(t, []interface{}{1}, args)
+
+	a := auditLog{enabled: true, tableName: "audit"}
+	query, args, err = a.wrap(ctx, auditEntry{event: AuditTokenRemoved, scope: "read", reason: "override"}, "DELETE FROM t WHERE id = $1", tokenAuditColumns, "", []interface{}{1})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(query, "WITH changed AS (DELETE FROM t WHERE id = $1 RETURNING "))
+	assert.Contains(t, query, "INSERT INTO audit (")
+	assert.Contains(t, query, "$2::timestamptz, $3::text")
+	require.Len(t, args, 8)
+	assert.Equal(t, []interface{}{string(AuditTokenRemoved), "", "read", "admin", "override", `{"ip":"127.0.0.1"}`}, args[2:])
+
+	_, args, err = a.wrap(context.Background(), auditEntry{event: AuditTokenExpired, actor: gcActor}, "DELETE FROM t", tokenAuditColumns, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{string(AuditTokenExpired), "", "", gcActor, "", "{}"}, args[1:])
+
+	// token hash and scope are taken from the source rows
+	query, args, err = a.insert(context.Background(), auditEntry{event: AuditTokenCreated, rowTokenHash: true}, "input", []interface{}{"{}"})
+	require.NoError(t, err)
+	assert.Contains(t, query, "SELECT $2::timestamptz, $3::text, tenant_id, client_id, user_id, token_id, token_hash, scope, $4::text, $5::text, $6::jsonb FROM input")
+	assert.Equal(t, []interface{}{string(AuditTokenCreated), "", "", "{}"}, args[2:])
+}
@@ -0,0 +1,229 @@
+package pg
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
+)
+
+// ClientMetadata is the client information with extended metadata, client store keeps it in dedicated
+// client table columns in addition to the encoded payload, so that it can be queried
+type ClientMetadata interface {
+	oauth2.ClientInfo
+
+	GetRedirectURIs() []string
+	GetGrantTypes() []string
+	GetScopes() []string
+	GetAccessTokenLifetime() time.Duration
+	GetRefreshTokenLifetime() time.Duration
+}
+
+// UnwrapClient returns the client information wrapped by the client store, e.g. by RotatingSecretClient,
+// or the client information itself if it is not wrapped
+func UnwrapClient(info oauth2.ClientInfo) oauth2.ClientInfo {
+	for {
+		wrapper, ok := info.(interface{ Unwrap() oauth2.ClientInfo })
+		if !ok {
+			return info
+		}
+		info = wrapper.Unwrap()
+	}
+}
+
+// Client is the client information model with extended metadata
+type Client struct {
+	ID     string
+	Secret string
+	Domain string
+	Public bool
+	UserID string
+
+	// RedirectURIs are the registered redirect URIs, the first one is used as domain if domain is not set
+	RedirectURIs []string
+	// GrantTypes are the grant types client is allowed to use, empty list means no restrictions
+	GrantTypes []string
+	// Scopes are the scopes client is allowed to request, empty list means no restrictions
+	Scopes []string
+	// AccessTokenLifetime is the client-specific access token lifetime, zero means server default
+	AccessTokenLifetime time.Duration
+	// RefreshTokenLifetime is the client-specific refresh token lifetime, zero means server default
+	RefreshTokenLifetime time.Duration
+}
+
+// NewClientInfo is the ClientInfoFactory for Client model
+func NewClientInfo() oauth2.ClientInfo {
+	return &Client{}
+}
+
+// GetID client id
+func (c *Client) GetID() string {
+	return c.ID
+}
+
+// GetSecret client secret
+func (c *Client) GetSecret() string {
+	return c.Secret
+}
+
+// GetDomain client domain, defaults to the first redirect URI
+func (c *Client) GetDomain() string {
+	if c.Domain == "" && len(c.RedirectURIs) > 0 {
+		return c.RedirectURIs[0]
+	}
+	return c.Domain
+}
+
+// IsPublic public
+func (c *Client) IsPublic() bool {
+	return c.Public
+}
+
+// GetUserID user id
+func (c *Client) GetUserID() string {
+	return c.UserID
+}
+
+// GetRedirectURIs registered redirect URIs
+func (c *Client) GetRedirectURIs() []string {
+	return c.RedirectURIs
+}
+
+// GetGrantTypes allowed grant types
+func (c *Client) GetGrantTypes() []string {
+	return c.GrantTypes
+}
+
+// GetScopes allowed scopes
+func (c *Client) GetScopes() []string {
+	return c.Scopes
+}
+
+// GetAccessTokenLifetime client-specific access token lifetime
+func (c *Client) GetAccessTokenLifetime() time.Duration {
+	return c.AccessTokenLifetime
+}
+
+// GetRefreshTokenLifetime client-specific refresh token lifetime
+func (c *Client) GetRefreshTokenLifetime() time.Duration {
+	return c.RefreshTokenLifetime
+}
+
+// ValidateRedirectURI checks that the redirect URI exactly matches one of the registered ones.
+// Port is ignored for loopback http redirect URIs as native apps use ephemeral ports, see RFC 8252 section 7.3.
+func (c *Client) ValidateRedirectURI(redirectURI string) error {
+	requested, err := url.Parse(redirectURI)
+	if err != nil {
+		return errors.ErrInvalidRedirectURI
+	}
+
+	for _, registered := range c.RedirectURIs {
+		if registered == redirectURI {
+			return nil
+		}
+
+		r, err := url.Parse(registered)
+		if err != nil || r.Scheme != "http" || requested.Scheme != "http" || !isLoopback(r.Hostname()) {
+			continue
+		}
+
+		if r.Hostname() == requested.Hostname() && r.Path == requested.Path && r.RawQuery == requested.RawQuery {
+			return nil
+		}
+	}
+
+	return errors.ErrInvalidRedirectURI
+}
+
+// AllowsGrant checks if the client is allowed to use the grant type
+func (c *Client) AllowsGrant(grantType oauth2.GrantType) bool {
+	if len(c.GrantTypes) == 0 {
+		return true
+	}
+
+	for _, gt := range c.GrantTypes {
+		if gt == grantType.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedScopes returns space-delimited requested scopes the client is allowed to request,
+// empty requested scope results in all the client scopes
+func (c *Client) AllowedScopes(requested string) string {
+	if len(c.Scopes) == 0 {
+		return requested
+	}
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(c.Scopes, " ")
+	}
+
+	allowed := make(map[string]bool, len(c.Scopes))
+	for _, s := range c.Scopes {
+		allowed[s] = true
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(requested) {
+		if allowed[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// clientColumns are the client metadata values of the dedicated client table columns
+type clientColumns struct {
+	RedirectURIs         string
+	GrantTypes           string
+	Scopes               string
+	AccessTokenLifetime  int64
+	RefreshTokenLifetime int64
+}
+
+func newClientColumns(info oauth2.ClientInfo) (clientColumns, error) {
+	columns := clientColumns{RedirectURIs: "[]", GrantTypes: "[]", Scopes: "[]"}
+
+	m, ok := UnwrapClient(info).(ClientMetadata)
+	if !ok {
+		return columns, nil
+	}
+
+	for _, c := range []struct {
+		dst    *string
+		values []string
+	}{
+		{&columns.RedirectURIs, m.GetRedirectURIs()},
+		{&columns.GrantTypes, m.GetGrantTypes()},
+		{&columns.Scopes, m.GetScopes()},
+	} {
+		if len(c.values) == 0 {
+			continue
+		}
+
+		buf, err := json.Marshal(c.values)
+		if err != nil {
+			return columns, err
+		}
+		*c.dst = string(buf)
+	}
+
+	columns.AccessTokenLifetime = int64(m.GetAccessTokenLifetime() / time.Second)
+	columns.RefreshTokenLifetime = int64(m.GetRefreshTokenLifetime() / time.Second)
+
+	return columns, nil
+}
@@ -2,45 +2,89 @@ package pg
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/go-oauth2/oauth2/v4"
-	"github.com/go-oauth2/oauth2/v4/models"
 
 	pgAdapter "github.com/vgarvardt/go-pg-adapter"
 )
 
 // ClientStore PostgreSQL client store
 type ClientStore struct {
-	adapter   pgAdapter.Adapter
-	tableName string
-	logger    Logger
+	adapter        pgAdapter.Adapter
+	tableName      string
+	tokenTableName string
+	logger         Logger
+	tenantID       string
+	codec          Codec
+	newInfo        ClientInfoFactory
 
 	initTableDisabled bool
+	rlsEnabled        bool
+
+	secretRotation   bool
+	secretsTableName string
+	gcDisabled       bool
+	gcInterval       time.Duration
+	ticker           *time.Ticker
+	gcStatus         *gcStatus
+
+	audit    auditLog
+	replicas *replicaSet
+	retry    retrier
+	limits   operationLimits
+	stats    statsSampler
+
+	statements clientStatements
 }
 
 // ClientStoreItem data item
 type ClientStoreItem struct {
-	ID     string `db:"id"`
-	Secret string `db:"secret"`
-	Domain string `db:"domain"`
-	Data   []byte `db:"data"`
+	ID                   string       `db:"id"`
+	TenantID             string       `db:"tenant_id"`
+	Secret               string       `db:"secret"`
+	Domain               string       `db:"domain"`
+	Public               bool         `db:"public"`
+	UserID               string       `db:"user_id"`
+	RedirectURIs         []byte       `db:"redirect_uris"`
+	GrantTypes           []byte       `db:"grant_types"`
+	Scopes               []byte       `db:"scopes"`
+	AccessTokenLifetime  int64        `db:"access_token_lifetime"`
+	RefreshTokenLifetime int64        `db:"refresh_token_lifetime"`
+	Disabled             bool         `db:"disabled"`
+	DisabledReason       string       `db:"disabled_reason"`
+	ExpiresAt            sql.NullTime `db:"expires_at"`
+	Data                 []byte       `db:"data"`
 }
 
 // NewClientStore creates PostgreSQL store instance
 func NewClientStore(adapter pgAdapter.Adapter, options ...ClientStoreOption) (*ClientStore, error) {
 	store := &ClientStore{
-		adapter:   adapter,
-		tableName: "oauth2_clients",
-		logger:    log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		adapter:        adapter,
+		tableName:      "oauth2_clients",
+		tokenTableName: "oauth2_tokens",
+		logger:         log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		codec:          JSONCodec{},
+		newInfo:        defaultClientInfoFactory,
+
+		secretsTableName: "oauth2_client_secrets",
+		gcInterval:       10 * time.Minute,
+		audit:            auditLog{tableName: DefaultAuditTableName},
+		replicas:         newReplicaSet(),
+		stats:            newStatsSampler(),
 	}
 
 	for _, o := range options {
 		o(store)
 	}
+	store.replicas.logger = store.logger
+	store.retry.logger = store.logger
+	store.limits.logger = store.logger
+	store.statements = newClientStatements(store.tableName, store.secretsTableName, store.secretRotation)
 
 	var err error
 	if !store.initTableDisabled {
@@ -51,54 +95,261 @@ func NewClientStore(adapter pgAdapter.Adapter, options ...ClientStoreOption) (*C
 		return store, err
 	}
 
+	// expired additional secrets are the only entities client store collects
+	if store.secretRotation && !store.gcDisabled {
+		store.ticker = time.NewTicker(store.gcInterval)
+		store.gcStatus = newGCStatus(store.gcInterval)
+		go store.gc()
+	}
+
 	return store, err
 }
 
+// Close closes the store
+func (s *ClientStore) Close() error {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	return nil
+}
+
+// WithTx returns a copy of the store bound to the transaction, so that store operations made with the copy
+// are committed or rolled back together with the caller changes. Use NewPGXTx or NewSQLTx to get the transaction
+// adapter. The copy does not run background jobs and closing it is no-op. Lookups made with the copy do not use
+// read replicas, so they see the transaction changes, and failed operations are not retried.
+func (s *ClientStore) WithTx(tx pgAdapter.Adapter) *ClientStore {
+	txStore := *s
+	txStore.adapter = tx
+	txStore.ticker = nil
+	txStore.gcStatus = nil
+	txStore.replicas = newReplicaSet()
+	// failed transaction can not be continued, so operations are not retried
+	txStore.retry.policy = nil
+	return &txStore
+}
+
+// db returns the transaction adapter bound to the context or the store adapter,
+// that sets the context tenant for row-level security policies if enabled
+func (s *ClientStore) db(ctx context.Context) pgAdapter.Adapter {
+	return rlsSession(resolveAdapter(ctx, s.adapter), s.rlsEnabled, TenantSetting, resolveTenant(ctx, s.tenantID))
+}
+
+// maintenance returns the store adapter that sees the rows of all tenants if row-level security is enabled
+func (s *ClientStore) maintenance() pgAdapter.Adapter {
+	return rlsSession(s.adapter, s.rlsEnabled, AllTenantsSetting, "on")
+}
+
 func (s *ClientStore) initTable() error {
-	return s.adapter.Exec(context.Background(), fmt.Sprintf(`
+	ctx, done := s.limits.start(context.Background(), opMigration, "client table init")
+	defer done()
+
+	err := s.adapter.Exec(ctx, fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS %[1]s (
-	"id"     TEXT  NOT NULL,
-	"secret" TEXT  NOT NULL,
-	"domain" TEXT  NOT NULL,
-	"data"   JSONB NOT NULL,
-	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+	"id"        TEXT  NOT NULL,
+	"tenant_id" TEXT  NOT NULL DEFAULT '',
+	"secret"    TEXT  NOT NULL,
+	"domain"    TEXT  NOT NULL,
+	"data"      %[2]s NOT NULL,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (tenant_id, id)
 );
-`, s.tableName))
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "tenant_id" TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_%[1]s_tenant_id ON %[1]s ("tenant_id");
+
+-- client ids are unique per tenant, primary key of the tables created before tenants were introduced is extended
+DO $$
+BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+		WHERE i.indrelid = '%[1]s'::regclass AND i.indisprimary AND a.attname = 'tenant_id'
+	) THEN
+		ALTER TABLE %[1]s DROP CONSTRAINT %[1]s_pkey, ADD CONSTRAINT %[1]s_pkey PRIMARY KEY (tenant_id, id);
+	END IF;
+END $$;
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "public" BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "user_id" TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "redirect_uris" JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "grant_types" JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "scopes" JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "access_token_lifetime" BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "refresh_token_lifetime" BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "disabled" BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "disabled_reason" TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "expires_at" TIMESTAMPTZ;
+
+COMMENT ON TABLE %[1]s IS '%[3]s';
+`, s.tableName, s.codec.DataType(), clientTableSchema.comment()))
+	if err == nil && s.secretRotation {
+		err = s.initSecretsTable(ctx)
+	}
+	if err == nil && s.audit.enabled {
+		err = initAuditTable(ctx, s.adapter, s.audit.tableName)
+	}
+	if err != nil || !s.rlsEnabled {
+		return err
+	}
+
+	return EnableRowLevelSecurity(ctx, s.adapter, s.tableName)
 }
 
 func (s *ClientStore) toClientInfo(data []byte) (oauth2.ClientInfo, error) {
-	var cm models.Client
-	err := json.Unmarshal(data, &cm)
-	return &cm, err
+	info := s.newInfo()
+	err := s.codec.Unmarshal(data, info)
+	return info, err
 }
 
-// GetByID retrieves and returns client information by id
+// GetByID retrieves and returns client information by id, lookup runs on a replica if any configured.
+// Returns ErrClientDisabled for disabled and expired clients.
 func (s *ClientStore) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
 	if id == "" {
 		return nil, nil
 	}
 
+	ctx, done := s.limits.start(ctx, opRead, "client lookup")
+	defer done()
+
+	if s.secretRotation {
+		return s.getRotatingSecretClient(ctx, id)
+	}
+
 	var item ClientStoreItem
-	if err := s.adapter.SelectOne(ctx, &item, fmt.Sprintf(`SELECT "id", "secret", "domain", "data" FROM "%s" WHERE "id" = $1`, s.tableName), id); err != nil {
+	if err := s.retry.do(ctx, "client lookup", func() error {
+		return s.replicas.selectOne(ctx, s.db(ctx), id, &item, s.statements.getByID, id, resolveTenant(ctx, s.tenantID))
+	}); err != nil {
 		return nil, err
 	}
+	if !item.active(time.Now()) {
+		return nil, ErrClientDisabled
+	}
 
 	return s.toClientInfo(item.Data)
 }
 
-// Create creates and stores the new client information
-func (s *ClientStore) Create(info oauth2.ClientInfo) error {
-	data, err := json.Marshal(info)
+// Create creates and stores the new client information in the store or context tenant.
+// Metadata of the ClientMetadata implementations is stored in dedicated columns as well.
+func (s *ClientStore) Create(ctx context.Context, info oauth2.ClientInfo) error {
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	columns, err := newClientColumns(info)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := s.limits.start(ctx, opWrite, "client create")
+	defer done()
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientCreated},
+		s.statements.create,
+		clientAuditColumns,
+		"",
+		[]interface{}{
+			info.GetID(),
+			resolveTenant(ctx, s.tenantID),
+			info.GetSecret(),
+			info.GetDomain(),
+			info.IsPublic(),
+			info.GetUserID(),
+			columns.RedirectURIs,
+			columns.GrantTypes,
+			columns.Scopes,
+			columns.AccessTokenLifetime,
+			columns.RefreshTokenLifetime,
+			data,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := s.retry.doWrite(ctx, "client create", func() error {
+		return s.db(ctx).Exec(ctx, query, args...)
+	}); err != nil {
+		return err
+	}
+
+	s.replicas.remember(info.GetID())
+	return nil
+}
+
+// Update updates stored client information
+func (s *ClientStore) Update(ctx context.Context, info oauth2.ClientInfo) error {
+	ctx, done := s.limits.start(ctx, opWrite, "client update")
+	defer done()
+
+	data, err := s.codec.Marshal(info)
 	if err != nil {
 		return err
 	}
 
-	return s.adapter.Exec(
-		context.Background(),
-		fmt.Sprintf(`INSERT INTO %s ("id", "secret", "domain", "data") VALUES ($1, $2, $3, $4)`, s.tableName),
-		info.GetID(),
-		info.GetSecret(),
-		info.GetDomain(),
-		data,
+	columns, err := newClientColumns(info)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientUpdated},
+		s.statements.update,
+		clientAuditColumns,
+		"",
+		[]interface{}{
+			info.GetSecret(),
+			info.GetDomain(),
+			info.IsPublic(),
+			info.GetUserID(),
+			columns.RedirectURIs,
+			columns.GrantTypes,
+			columns.Scopes,
+			columns.AccessTokenLifetime,
+			columns.RefreshTokenLifetime,
+			data,
+			info.GetID(),
+			resolveTenant(ctx, s.tenantID),
+		},
 	)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db(ctx).Exec(ctx, query, args...); err != nil {
+		return err
+	}
+	s.replicas.remember(info.GetID())
+	return nil
+}
+
+// RemoveByID deletes client information by id
+func (s *ClientStore) RemoveByID(ctx context.Context, id string) error {
+	ctx, done := s.limits.start(ctx, opWrite, "client remove")
+	defer done()
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientRemoved},
+		s.statements.remove,
+		clientAuditColumns,
+		"",
+		[]interface{}{id, resolveTenant(ctx, s.tenantID)},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = s.retry.do(ctx, "client remove", func() error {
+		return s.db(ctx).Exec(ctx, query, args...)
+	})
+	if err == pgAdapter.ErrNoRows {
+		err = nil
+	}
+	if err == nil {
+		s.replicas.remember(id)
+	}
+	return err
 }
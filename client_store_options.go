@@ -1,5 +1,11 @@
 package pg
 
+import (
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
 // ClientStoreOption is the configuration options type for client store
 type ClientStoreOption func(s *ClientStore)
 
@@ -10,6 +16,14 @@ func WithClientStoreTableName(tableName string) ClientStoreOption {
 	}
 }
 
+// WithClientStoreTokenTableName returns option that sets token store table name,
+// client tokens are revoked from it when client is disabled
+func WithClientStoreTokenTableName(tableName string) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.tokenTableName = tableName
+	}
+}
+
 // WithClientStoreLogger returns option that sets client store logger implementation
 func WithClientStoreLogger(logger Logger) ClientStoreOption {
 	return func(s *ClientStore) {
@@ -23,3 +37,127 @@ func WithClientStoreInitTableDisabled() ClientStoreOption {
 		s.initTableDisabled = true
 	}
 }
+
+// WithClientStoreTenantID returns option that scopes client store to the tenant,
+// tenant set to the operation context with WithTenant takes precedence
+func WithClientStoreTenantID(tenantID string) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithClientStoreRowLevelSecurity returns option that enables tenant row-level security policy on table creation,
+// see EnableRowLevelSecurity for details
+func WithClientStoreRowLevelSecurity() ClientStoreOption {
+	return func(s *ClientStore) {
+		s.rlsEnabled = true
+	}
+}
+
+// WithClientStoreCodec returns option that sets client store payload codec and the factory of the client information
+// type payloads are decoded into, nil factory keeps the default models.Client type.
+// Codec data type is used on table creation only, so changing codec for the existing table requires migration.
+func WithClientStoreCodec(codec Codec, factory ClientInfoFactory) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.codec = codec
+		if factory != nil {
+			s.newInfo = factory
+		}
+	}
+}
+
+// WithClientStoreSecretRotation returns option that enables additional client secrets, so that clients
+// can have several valid secrets at the same time. Client information is returned as RotatingSecretClient
+// that accepts any of the valid secrets.
+func WithClientStoreSecretRotation() ClientStoreOption {
+	return func(s *ClientStore) {
+		s.secretRotation = true
+	}
+}
+
+// WithClientStoreSecretsTableName returns option that sets client store additional secrets table name
+func WithClientStoreSecretsTableName(tableName string) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.secretsTableName = tableName
+	}
+}
+
+// WithClientStoreGCInterval returns option that sets client store garbage collection interval. Garbage collection
+// of expired additional secrets runs when secret rotation is enabled, store must be closed to stop it.
+func WithClientStoreGCInterval(gcInterval time.Duration) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.gcInterval = gcInterval
+	}
+}
+
+// WithClientStoreGCDisabled returns option that disables client store garbage collection
+func WithClientStoreGCDisabled() ClientStoreOption {
+	return func(s *ClientStore) {
+		s.gcDisabled = true
+	}
+}
+
+// WithClientStoreAudit returns option that enables audit log of client mutations,
+// audit records are written by the same statements as the changes
+func WithClientStoreAudit() ClientStoreOption {
+	return func(s *ClientStore) {
+		s.audit.enabled = true
+	}
+}
+
+// WithClientStoreAuditTableName returns option that sets audit log table name
+func WithClientStoreAuditTableName(tableName string) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.audit.tableName = tableName
+	}
+}
+
+// WithClientStoreReplicas returns option that routes client lookups to the read replicas in round-robin manner,
+// store adapter is the primary used for all the other operations
+func WithClientStoreReplicas(replicas ...pgAdapter.Adapter) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.replicas.adapters = replicas
+	}
+}
+
+// WithClientStoreReadYourWritesWindow returns option that sets the time client lookups fall back to the primary
+// when replica does not have the client created by the store yet, default is DefaultReadYourWritesWindow.
+// Only the clients created by the same store instance are tracked.
+func WithClientStoreReadYourWritesWindow(window time.Duration) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.replicas.window = window
+	}
+}
+
+// WithClientStoreRetryPolicy returns option that enables retries of client lookups and removals failed with
+// transient PostgreSQL errors, see IsRetryable, and of client creation failed with serialization failure or deadlock.
+// Every retry is logged with the store logger.
+func WithClientStoreRetryPolicy(policy RetryPolicy) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.retry.policy = &policy
+	}
+}
+
+// WithClientStoreTimeouts returns option that sets client store operations default timeouts,
+// applied only when the operation context has no deadline
+func WithClientStoreTimeouts(timeouts OperationTimeouts) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.limits.timeouts = timeouts
+	}
+}
+
+// WithClientStoreSlowQueryThreshold returns option that enables logging of the client store operations
+// that took at least threshold time
+func WithClientStoreSlowQueryThreshold(threshold time.Duration) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.limits.slowThreshold = threshold
+	}
+}
+
+// WithClientStoreStatsExactCountLimit returns option that sets the table rows estimate up to which statistics
+// are counted exactly, bigger tables are sampled. Zero limit disables sampling.
+func WithClientStoreStatsExactCountLimit(exactCountLimit int64) ClientStoreOption {
+	return func(s *ClientStore) {
+		s.stats.exactCountLimit = exactCountLimit
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-oauth2/oauth2/v4/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
 )
 
 func TestWithClientStoreInitTableDisabled(t *testing.T) {
@@ -47,3 +50,96 @@ func TestWithClientStoreLogger(t *testing.T) {
 	assert.Equal(t, 12, l.args[1][0])
 	assert.Equal(t, "22", l.args[1][1])
 }
+
+func TestWithClientStoreTenantID(t *testing.T) {
+	randomTenant := time.Now().String()
+
+	store, err := NewClientStore(nil, WithClientStoreTenantID(randomTenant), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomTenant, store.tenantID)
+}
+
+func TestWithClientStoreRowLevelSecurity(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreRowLevelSecurity(), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.rlsEnabled)
+}
+
+func TestWithClientStoreCodec(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, JSONCodec{}, store.codec)
+	assert.IsType(t, &models.Client{}, store.newInfo())
+
+	store, err = NewClientStore(nil, WithClientStoreCodec(GobCodec{}, newTenantClient), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, GobCodec{}, store.codec)
+	assert.IsType(t, &tenantClient{}, store.newInfo())
+}
+
+func TestWithClientStoreSecretRotation(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewClientStore(nil, WithClientStoreSecretRotation(), WithClientStoreSecretsTableName(randomName), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.secretRotation)
+	assert.Equal(t, randomName, store.secretsTableName)
+}
+
+func TestWithClientStoreGCInterval(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreGCInterval(time.Hour), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, store.gcInterval)
+	assert.Nil(t, store.ticker)
+
+	store, err = NewClientStore(nil, WithClientStoreGCInterval(time.Hour), WithClientStoreSecretRotation(), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.NotNil(t, store.ticker)
+	assert.NoError(t, store.Close())
+}
+
+func TestWithClientStoreGCDisabled(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreSecretRotation(), WithClientStoreGCDisabled(), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.gcDisabled)
+	assert.Nil(t, store.ticker)
+}
+
+func TestWithClientStoreTokenTableName(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewClientStore(nil, WithClientStoreTokenTableName(randomName), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomName, store.tokenTableName)
+}
+
+func TestWithClientStoreReplicas(t *testing.T) {
+	replica := new(mockAdapter)
+
+	store, err := NewClientStore(nil, WithClientStoreReplicas(replica), WithClientStoreReadYourWritesWindow(time.Minute), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, []pgAdapter.Adapter{replica}, store.replicas.adapters)
+	assert.Equal(t, time.Minute, store.replicas.window)
+}
+
+func TestWithClientStoreRetryPolicy(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreRetryPolicy(DefaultRetryPolicy()), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	require.NotNil(t, store.retry.policy)
+	assert.Equal(t, DefaultRetryPolicy(), *store.retry.policy)
+}
+
+func TestWithClientStoreTimeouts(t *testing.T) {
+	timeouts := OperationTimeouts{Read: time.Second, Write: 2 * time.Second, GC: time.Minute, Migration: time.Hour}
+
+	store, err := NewClientStore(nil, WithClientStoreTimeouts(timeouts), WithClientStoreSlowQueryThreshold(time.Second), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, timeouts, store.limits.timeouts)
+	assert.Equal(t, time.Second, store.limits.slowThreshold)
+}
+
+func TestWithClientStoreStatsExactCountLimit(t *testing.T) {
+	store, err := NewClientStore(nil, WithClientStoreStatsExactCountLimit(100), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), store.stats.exactCountLimit)
+}
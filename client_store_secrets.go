@@ -0,0 +1,250 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// ClientSecret is the additional client secret data item, secret itself is never stored, only its hash
+type ClientSecret struct {
+	ID        int64      `db:"id" json:"id"`
+	TenantID  string     `db:"tenant_id" json:"tenant_id"`
+	ClientID  string     `db:"client_id" json:"client_id"`
+	Label     string     `db:"label" json:"label"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// clientSecretColumns is the explicit client secrets table column list, matches clientSecretRow fields
+const clientSecretColumns = "id, tenant_id, client_id, label, created_at, expires_at"
+
+// clientSecretRow is the client secrets table row without the secret hash
+type clientSecretRow struct {
+	ID        int64        `db:"id"`
+	TenantID  string       `db:"tenant_id"`
+	ClientID  string       `db:"client_id"`
+	Label     string       `db:"label"`
+	CreatedAt time.Time    `db:"created_at"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+}
+
+func (r *clientSecretRow) clientSecret() *ClientSecret {
+	return &ClientSecret{
+		ID:        r.ID,
+		TenantID:  r.TenantID,
+		ClientID:  r.ClientID,
+		Label:     r.Label,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: nullTime(r.ExpiresAt),
+	}
+}
+
+// RotatingSecretClient is the client information returned by the client store with secret rotation enabled.
+// It implements oauth2.ClientPasswordVerifier accepting client primary secret, if set, and any of the
+// additional secrets that were not expired at the moment client information was loaded.
+// Use UnwrapClient to check the concrete type of the wrapped client information, e.g. ClientMetadata.
+type RotatingSecretClient struct {
+	oauth2.ClientInfo
+
+	secretHashes []string
+}
+
+// Unwrap returns the client information loaded from the client store
+func (c *RotatingSecretClient) Unwrap() oauth2.ClientInfo {
+	return c.ClientInfo
+}
+
+// VerifyPassword checks if the secret is one of the valid client secrets
+func (c *RotatingSecretClient) VerifyPassword(secret string) bool {
+	if primary := c.GetSecret(); primary != "" && subtle.ConstantTimeCompare([]byte(primary), []byte(secret)) == 1 {
+		return true
+	}
+
+	hash := []byte(hashSecret(secret))
+	valid := false
+	for _, h := range c.secretHashes {
+		// do not break early to not leak the number of secrets checked
+		if subtle.ConstantTimeCompare([]byte(h), hash) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+type rotatingSecretClientItem struct {
+	ClientStoreItem
+	SecretHashes []byte `db:"secret_hashes"`
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *ClientStore) initSecretsTable(ctx context.Context) error {
+	err := s.adapter.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id          BIGSERIAL   NOT NULL,
+	tenant_id   TEXT        NOT NULL DEFAULT '',
+	client_id   TEXT        NOT NULL,
+	secret_hash TEXT        NOT NULL,
+	label       TEXT        NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	expires_at  TIMESTAMPTZ,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_%[1]s_client_id ON %[1]s (client_id);
+CREATE INDEX IF NOT EXISTS idx_%[1]s_expires_at ON %[1]s (expires_at);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = '%[1]s'::regclass AND conname = '%[1]s_client_fkey') THEN
+		-- secrets added before tenants were kept belong to the client tenant, secrets of removed clients are dropped
+		UPDATE %[1]s s SET tenant_id = c.tenant_id FROM %[3]s c WHERE c.id = s.client_id;
+		DELETE FROM %[1]s s WHERE NOT EXISTS (SELECT 1 FROM %[3]s c WHERE c.tenant_id = s.tenant_id AND c.id = s.client_id);
+		ALTER TABLE %[1]s ADD CONSTRAINT %[1]s_client_fkey
+			FOREIGN KEY (tenant_id, client_id) REFERENCES %[3]s (tenant_id, id) ON DELETE CASCADE;
+	END IF;
+END $$;
+
+COMMENT ON TABLE %[1]s IS '%[2]s';
+`, s.secretsTableName, clientSecretsTableSchema.comment(), s.tableName))
+	if err != nil || !s.rlsEnabled {
+		return err
+	}
+
+	return EnableRowLevelSecurity(ctx, s.adapter, s.secretsTableName)
+}
+
+func (s *ClientStore) getRotatingSecretClient(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	var item rotatingSecretClientItem
+	if err := s.retry.do(ctx, "client lookup", func() error {
+		return s.replicas.selectOne(ctx, s.db(ctx), id, &item, s.statements.getByID, id, resolveTenant(ctx, s.tenantID), time.Now())
+	}); err != nil {
+		return nil, err
+	}
+	if !item.active(time.Now()) {
+		return nil, ErrClientDisabled
+	}
+
+	info, err := s.toClientInfo(item.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &RotatingSecretClient{ClientInfo: info}
+	err = json.Unmarshal(item.SecretHashes, &client.secretHashes)
+	return client, err
+}
+
+// AddSecret adds one more valid secret to the client of the store or context tenant, zero expiresIn means
+// the secret never expires. Client store secret rotation must be enabled for additional secrets to be accepted.
+// Returns pgAdapter.ErrNoRows if there is no such client.
+func (s *ClientStore) AddSecret(ctx context.Context, clientID, secret, label string, expiresIn time.Duration) (*ClientSecret, error) {
+	ctx, done := s.limits.start(ctx, opWrite, "client secret add")
+	defer done()
+
+	now := time.Now()
+
+	var expiresAt *time.Time
+	if expiresIn > 0 {
+		t := now.Add(expiresIn)
+		expiresAt = &t
+	}
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientSecretAdded},
+		fmt.Sprintf(`
+INSERT INTO %s (tenant_id, client_id, secret_hash, label, created_at, expires_at)
+SELECT c.tenant_id, c.id, $3, $4, $5, $6 FROM %s c WHERE c.id = $1 AND c.tenant_id = $2`, s.secretsTableName, s.tableName),
+		"id, label, created_at, expires_at, "+s.secretAuditColumns(),
+		fmt.Sprintf("SELECT %s FROM changed", clientSecretColumns),
+		[]interface{}{clientID, resolveTenant(ctx, s.tenantID), hashSecret(secret), label, now, expiresAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var item clientSecretRow
+	if err := s.db(ctx).SelectOne(ctx, &item, query, args...); err != nil {
+		return nil, err
+	}
+
+	return item.clientSecret(), nil
+}
+
+// RevokeSecret revokes additional secret of the client of the store or context tenant after the grace period,
+// so that client instances have time to switch to the new secret. Zero grace period revokes the secret immediately.
+func (s *ClientStore) RevokeSecret(ctx context.Context, clientID string, secretID int64, gracePeriod time.Duration) error {
+	ctx, done := s.limits.start(ctx, opWrite, "client secret revoke")
+	defer done()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE client_id = $1 AND id = $2 AND tenant_id = $3", s.secretsTableName)
+	args := []interface{}{clientID, secretID, resolveTenant(ctx, s.tenantID)}
+	if gracePeriod > 0 {
+		query = fmt.Sprintf("UPDATE %s SET expires_at = LEAST(expires_at, $4) WHERE client_id = $1 AND id = $2 AND tenant_id = $3", s.secretsTableName)
+		args = append(args, time.Now().Add(gracePeriod))
+	}
+
+	query, args, err := s.audit.wrap(ctx, auditEntry{event: AuditClientSecretRevoked}, query, s.secretAuditColumns(), "", args)
+	if err != nil {
+		return err
+	}
+
+	return s.db(ctx).Exec(ctx, query, args...)
+}
+
+// secretAuditColumns are the secrets table columns of the audit records, user is taken from the client
+func (s *ClientStore) secretAuditColumns() string {
+	return fmt.Sprintf(`client_id, tenant_id,
+	COALESCE((SELECT c.user_id FROM %[1]s c WHERE c.tenant_id = %[2]s.tenant_id AND c.id = %[2]s.client_id), '') AS user_id,
+	NULL::bigint AS token_id`, s.tableName, s.secretsTableName)
+}
+
+// ListSecrets returns not expired additional secrets of the client of the store or context tenant
+func (s *ClientStore) ListSecrets(ctx context.Context, clientID string) ([]*ClientSecret, error) {
+	ctx, done := s.limits.start(ctx, opRead, "client secrets lookup")
+	defer done()
+
+	var items []*ClientSecret
+	err := selectAll(
+		ctx,
+		s.db(ctx),
+		&items,
+		fmt.Sprintf("SELECT %s FROM %s WHERE client_id = $1 AND tenant_id = $2 AND (expires_at IS NULL OR expires_at > $3) ORDER BY id", clientSecretColumns, s.secretsTableName),
+		clientID,
+		resolveTenant(ctx, s.tenantID),
+		time.Now(),
+	)
+	return items, err
+}
+
+func (s *ClientStore) gc() {
+	for range s.ticker.C {
+		s.clean()
+	}
+}
+
+func (s *ClientStore) clean() {
+	ctx, done := s.limits.start(context.Background(), opGC, "client secrets gc")
+	defer done()
+
+	now := time.Now()
+	err := s.maintenance().Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.secretsTableName), now)
+	s.gcStatus.record(err)
+	if err != nil {
+		s.logger.Printf("Error while cleaning out outdated entities: %+v", err)
+	}
+}
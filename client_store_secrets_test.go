@@ -0,0 +1,117 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestRotatingSecretClient_VerifyPassword(t *testing.T) {
+	client := &RotatingSecretClient{
+		ClientInfo:   &models.Client{ID: "id", Secret: "primary"},
+		secretHashes: []string{hashSecret("old"), hashSecret("new")},
+	}
+
+	assert.True(t, client.VerifyPassword("primary"))
+	assert.True(t, client.VerifyPassword("old"))
+	assert.True(t, client.VerifyPassword("new"))
+	assert.False(t, client.VerifyPassword("unknown"))
+	assert.False(t, client.VerifyPassword(""))
+
+	client.ClientInfo = &models.Client{ID: "id"}
+	assert.False(t, client.VerifyPassword(""))
+}
+
+func TestClientStore_Secrets(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	clientTableName := generateClientTableName()
+	store, err := NewClientStore(
+		pgx4adapter.NewPool(pool),
+		WithClientStoreTableName(clientTableName),
+		WithClientStoreSecretsTableName(clientTableName+"_secrets"),
+		WithClientStoreSecretRotation(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	ctx := context.Background()
+	clientID := fmt.Sprintf("id %s", time.Now().String())
+	require.NoError(t, store.Create(ctx, &models.Client{ID: clientID}))
+
+	oldSecret, err := store.AddSecret(ctx, clientID, "old", "initial", 0)
+	require.NoError(t, err)
+	assert.Nil(t, oldSecret.ExpiresAt)
+
+	_, err = store.AddSecret(ctx, clientID, "new", "rotated", time.Hour)
+	require.NoError(t, err)
+
+	_, err = store.AddSecret(ctx, clientID, "expired", "expired", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	client, err := store.GetByID(ctx, clientID)
+	require.NoError(t, err)
+	verifier, ok := client.(oauth2.ClientPasswordVerifier)
+	require.True(t, ok)
+	assert.True(t, verifier.VerifyPassword("old"))
+	assert.True(t, verifier.VerifyPassword("new"))
+	assert.False(t, verifier.VerifyPassword("expired"))
+
+	secrets, err := store.ListSecrets(ctx, clientID)
+	require.NoError(t, err)
+	require.Len(t, secrets, 2)
+	assert.Equal(t, "initial", secrets[0].Label)
+	assert.Equal(t, "rotated", secrets[1].Label)
+
+	require.NoError(t, store.RevokeSecret(ctx, clientID, oldSecret.ID, time.Hour))
+	secrets, err = store.ListSecrets(ctx, clientID)
+	require.NoError(t, err)
+	require.Len(t, secrets, 2)
+	assert.NotNil(t, secrets[0].ExpiresAt)
+
+	require.NoError(t, store.RevokeSecret(ctx, clientID, oldSecret.ID, 0))
+	store.clean()
+
+	client, err = store.GetByID(ctx, clientID)
+	require.NoError(t, err)
+	verifier = client.(oauth2.ClientPasswordVerifier)
+	assert.False(t, verifier.VerifyPassword("old"))
+	assert.True(t, verifier.VerifyPassword("new"))
+
+	_, err = store.AddSecret(ctx, "unknown "+clientID, "secret", "unknown", 0)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	tenantCtx := WithTenant(ctx, "tenant")
+	_, err = store.AddSecret(tenantCtx, clientID, "secret", "other tenant", 0)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	require.NoError(t, store.Create(tenantCtx, &models.Client{ID: clientID}))
+	tenantSecret, err := store.AddSecret(tenantCtx, clientID, "tenant", "tenant", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant", tenantSecret.TenantID)
+
+	secrets, err = store.ListSecrets(tenantCtx, clientID)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, tenantSecret.ID, secrets[0].ID)
+
+	require.NoError(t, store.RevokeSecret(ctx, clientID, tenantSecret.ID, 0))
+	client, err = store.GetByID(tenantCtx, clientID)
+	require.NoError(t, err)
+	assert.True(t, client.(oauth2.ClientPasswordVerifier).VerifyPassword("tenant"))
+	assert.False(t, client.(oauth2.ClientPasswordVerifier).VerifyPassword("new"))
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrClientDisabled is returned on client retrieval when the client is disabled or expired
+var ErrClientDisabled = errors.New("client is disabled")
+
+// active checks that the client is neither disabled nor expired at the given time
+func (i ClientStoreItem) active(now time.Time) bool {
+	return !i.Disabled && (!i.ExpiresAt.Valid || i.ExpiresAt.Time.After(now))
+}
+
+// Disable suspends the client keeping its configuration, so GetByID returns ErrClientDisabled for it.
+// With revokeTokens set all the client tokens are deleted from the token store table in the same statement.
+// Returns pgAdapter.ErrNoRows if there is no such client.
+func (s *ClientStore) Disable(ctx context.Context, id, reason string, revokeTokens bool) error {
+	query := fmt.Sprintf(`
+WITH disabled AS (
+	UPDATE %[1]s SET "disabled" = true, "disabled_reason" = $3
+	WHERE "id" = $1 AND "tenant_id" = $2
+	RETURNING %[3]s
+), revoked AS (
+	DELETE FROM %[2]s
+	WHERE $4 AND ("client_id", "tenant_id") IN (SELECT client_id, tenant_id FROM disabled)
+	RETURNING %[4]s
+)`, s.tableName, s.tokenTableName, clientAuditColumns, tokenAuditColumns)
+	args := []interface{}{id, resolveTenant(ctx, s.tenantID), reason, revokeTokens}
+
+	if s.audit.enabled {
+		var (
+			clientAudit, tokenAudit string
+			err                     error
+		)
+		if clientAudit, args, err = s.audit.insert(ctx, auditEntry{event: AuditClientDisabled, reason: reason}, "disabled", args); err != nil {
+			return err
+		}
+		if tokenAudit, args, err = s.audit.insert(ctx, auditEntry{event: AuditTokenRemoved, reason: reason}, "revoked", args); err != nil {
+			return err
+		}
+		query += fmt.Sprintf(", client_audited AS (%s), token_audited AS (%s)", clientAudit, tokenAudit)
+	}
+
+	return s.selectChanged(ctx, query+"\nSELECT client_id AS id FROM disabled", args)
+}
+
+// Enable resumes previously disabled client. Returns pgAdapter.ErrNoRows if there is no such client.
+func (s *ClientStore) Enable(ctx context.Context, id string) error {
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientEnabled},
+		fmt.Sprintf(`UPDATE %s SET "disabled" = false, "disabled_reason" = '' WHERE "id" = $1 AND "tenant_id" = $2`, s.tableName),
+		clientAuditColumns,
+		"SELECT client_id AS id FROM changed",
+		[]interface{}{id, resolveTenant(ctx, s.tenantID)},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.selectChanged(ctx, query, args)
+}
+
+// SetExpiresAt sets the time client expires at, GetByID returns ErrClientDisabled for expired clients.
+// Nil expiresAt makes the client never expire. Returns pgAdapter.ErrNoRows if there is no such client.
+func (s *ClientStore) SetExpiresAt(ctx context.Context, id string, expiresAt *time.Time) error {
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditClientExpirationChanged},
+		fmt.Sprintf(`UPDATE %s SET "expires_at" = $3 WHERE "id" = $1 AND "tenant_id" = $2`, s.tableName),
+		clientAuditColumns,
+		"SELECT client_id AS id FROM changed",
+		[]interface{}{id, resolveTenant(ctx, s.tenantID), expiresAt},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.selectChanged(ctx, query, args)
+}
+
+// selectChanged runs the query returning changed client id, so that missing client results in pgAdapter.ErrNoRows
+func (s *ClientStore) selectChanged(ctx context.Context, query string, args []interface{}) error {
+	ctx, done := s.limits.start(ctx, opWrite, "client status update")
+	defer done()
+
+	var item struct {
+		ID string `db:"id"`
+	}
+	if err := s.db(ctx).SelectOne(ctx, &item, query, args...); err != nil {
+		return err
+	}
+	s.replicas.remember(item.ID)
+	return nil
+}
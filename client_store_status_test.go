@@ -0,0 +1,78 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestClientStore_Disable(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	tokenTableName := generateTokenTableName()
+
+	tokenStore, err := NewTokenStore(adapter, WithTokenStoreTableName(tokenTableName), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	clientStore, err := NewClientStore(adapter, WithClientStoreTableName(generateClientTableName()), WithClientStoreTokenTableName(tokenTableName))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := &models.Client{ID: fmt.Sprintf("id %s", time.Now().String()), Secret: "secret"}
+	require.NoError(t, clientStore.Create(ctx, client))
+
+	access := fmt.Sprintf("access %s", time.Now().String())
+	token := models.NewToken()
+	token.SetClientID(client.ID)
+	token.SetAccess(access)
+	token.SetAccessCreateAt(time.Now())
+	token.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, tokenStore.Create(ctx, token))
+
+	require.NoError(t, clientStore.Disable(ctx, client.ID, "compromised", false))
+	_, err = clientStore.GetByID(ctx, client.ID)
+	assert.Equal(t, ErrClientDisabled, err)
+
+	_, err = tokenStore.GetByAccess(ctx, access)
+	require.NoError(t, err)
+
+	require.NoError(t, clientStore.Enable(ctx, client.ID))
+	_, err = clientStore.GetByID(ctx, client.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, clientStore.Disable(ctx, client.ID, "compromised", true))
+	_, err = clientStore.GetByID(ctx, client.ID)
+	assert.Equal(t, ErrClientDisabled, err)
+
+	_, err = tokenStore.GetByAccess(ctx, access)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	require.NoError(t, clientStore.Enable(ctx, client.ID))
+
+	expiresAt := time.Now().Add(-time.Second)
+	require.NoError(t, clientStore.SetExpiresAt(ctx, client.ID, &expiresAt))
+	_, err = clientStore.GetByID(ctx, client.ID)
+	assert.Equal(t, ErrClientDisabled, err)
+
+	require.NoError(t, clientStore.SetExpiresAt(ctx, client.ID, nil))
+	_, err = clientStore.GetByID(ctx, client.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, pgAdapter.ErrNoRows, clientStore.Disable(ctx, "unknown", "", true))
+	assert.Equal(t, pgAdapter.ErrNoRows, clientStore.Enable(ctx, "unknown"))
+}
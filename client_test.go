@@ -0,0 +1,86 @@
+package pg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ValidateRedirectURI(t *testing.T) {
+	c := &Client{RedirectURIs: []string{"https://client.example.com/cb", "http://127.0.0.1:8080/cb", "http://localhost/cb"}}
+
+	assert.NoError(t, c.ValidateRedirectURI("https://client.example.com/cb"))
+	assert.NoError(t, c.ValidateRedirectURI("http://127.0.0.1:51234/cb"))
+	assert.NoError(t, c.ValidateRedirectURI("http://localhost:4000/cb"))
+
+	for _, uri := range []string{
+		"https://client.example.com/cb/",
+		"https://client.example.com:8443/cb",
+		"https://client.example.com/cb?foo=bar",
+		"http://127.0.0.1:8080/other",
+		"http://[::1]:8080/cb",
+		"://invalid",
+	} {
+		assert.Equal(t, errors.ErrInvalidRedirectURI, c.ValidateRedirectURI(uri), uri)
+	}
+
+	assert.Equal(t, "https://client.example.com/cb", c.GetDomain())
+}
+
+func TestClient_AllowsGrant(t *testing.T) {
+	assert.True(t, (&Client{}).AllowsGrant(oauth2.Implicit))
+
+	c := &Client{GrantTypes: []string{oauth2.AuthorizationCode.String(), oauth2.Refreshing.String()}}
+	assert.True(t, c.AllowsGrant(oauth2.AuthorizationCode))
+	assert.True(t, c.AllowsGrant(oauth2.Refreshing))
+	assert.False(t, c.AllowsGrant(oauth2.ClientCredentials))
+}
+
+func TestClient_AllowedScopes(t *testing.T) {
+	assert.Equal(t, "admin", (&Client{}).AllowedScopes("admin"))
+
+	c := &Client{Scopes: []string{"openid", "profile"}}
+	assert.Equal(t, "openid profile", c.AllowedScopes(""))
+	assert.Equal(t, "openid", c.AllowedScopes("openid admin"))
+	assert.Equal(t, "", c.AllowedScopes("admin"))
+}
+
+func TestNewClientColumns(t *testing.T) {
+	columns, err := newClientColumns(&models.Client{ID: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, clientColumns{RedirectURIs: "[]", GrantTypes: "[]", Scopes: "[]"}, columns)
+
+	columns, err = newClientColumns(&Client{
+		ID:                   "id",
+		RedirectURIs:         []string{"https://client.example.com/cb"},
+		Scopes:               []string{"openid"},
+		AccessTokenLifetime:  time.Hour,
+		RefreshTokenLifetime: 24 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, clientColumns{
+		RedirectURIs:         `["https://client.example.com/cb"]`,
+		GrantTypes:           "[]",
+		Scopes:               `["openid"]`,
+		AccessTokenLifetime:  3600,
+		RefreshTokenLifetime: 86400,
+	}, columns)
+
+	// client loaded with secret rotation enabled keeps its metadata
+	columns, err = newClientColumns(&RotatingSecretClient{ClientInfo: &Client{ID: "id", Scopes: []string{"openid"}}})
+	require.NoError(t, err)
+	assert.Equal(t, `["openid"]`, columns.Scopes)
+}
+
+func TestUnwrapClient(t *testing.T) {
+	client := &Client{ID: "id"}
+
+	assert.Same(t, client, UnwrapClient(client))
+	assert.Same(t, client, UnwrapClient(&RotatingSecretClient{ClientInfo: client}))
+	assert.Same(t, client, UnwrapClient(&RotatingSecretClient{ClientInfo: &RotatingSecretClient{ClientInfo: client}}))
+}
@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// Codec encodes and decodes token and client information payloads stored in the data column
+type Codec interface {
+	// Marshal encodes the value
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into the value, v is always a pointer created by the store factory
+	Unmarshal(data []byte, v interface{}) error
+	// DataType returns PostgreSQL type of the data column encoded payloads are stored in
+	DataType() string
+}
+
+// TokenInfoFactory creates new empty token information instance to decode stored payload into
+type TokenInfoFactory func() oauth2.TokenInfo
+
+// ClientInfoFactory creates new empty client information instance to decode stored payload into
+type ClientInfoFactory func() oauth2.ClientInfo
+
+// JSONCodec stores payloads as JSON in JSONB column, this is the default codec
+type JSONCodec struct{}
+
+// Marshal encodes the value to JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into the value
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// DataType returns JSONB column type
+func (JSONCodec) DataType() string {
+	return "JSONB"
+}
+
+// GobCodec stores payloads in compact binary encoding/gob format in BYTEA column.
+// Concrete types that have interface fields must be registered with gob.Register.
+type GobCodec struct{}
+
+// Marshal encodes the value to gob
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into the value
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// DataType returns BYTEA column type
+func (GobCodec) DataType() string {
+	return "BYTEA"
+}
+
+func defaultTokenInfoFactory() oauth2.TokenInfo {
+	return models.NewToken()
+}
+
+func defaultClientInfoFactory() oauth2.ClientInfo {
+	return &models.Client{}
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+type tenantToken struct {
+	models.Token
+	TenantID string
+	ACR      string
+}
+
+func newTenantToken() oauth2.TokenInfo {
+	return &tenantToken{}
+}
+
+type tenantClient struct {
+	models.Client
+	TenantID string
+}
+
+func newTenantClient() oauth2.ClientInfo {
+	return &tenantClient{}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		t.Run(codec.DataType(), func(t *testing.T) {
+			original := &tenantToken{TenantID: "tenant", ACR: "urn:mace:incommon:iap:silver"}
+			original.SetAccess("access")
+			original.SetAccessCreateAt(time.Now().UTC().Truncate(time.Second))
+			original.SetAccessExpiresIn(time.Hour)
+
+			data, err := codec.Marshal(original)
+			require.NoError(t, err)
+
+			decoded := newTenantToken()
+			require.NoError(t, codec.Unmarshal(data, decoded))
+			assert.Equal(t, original, decoded)
+		})
+	}
+}
+
+func TestGobCodecStores(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := pgx4adapter.NewPool(pool)
+	ctx := context.Background()
+
+	tokenStore, err := NewTokenStore(
+		adapter,
+		WithTokenStoreTableName(generateTokenTableName()),
+		WithTokenStoreGCDisabled(),
+		WithTokenStoreCodec(GobCodec{}, newTenantToken),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	access := fmt.Sprintf("access %s", time.Now().String())
+	originalToken := &tenantToken{TenantID: "tenant", ACR: "acr"}
+	originalToken.SetAccess(access)
+	originalToken.SetAccessCreateAt(time.Now())
+	originalToken.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, tokenStore.Create(ctx, originalToken))
+
+	token, err := tokenStore.GetByAccess(ctx, access)
+	require.NoError(t, err)
+	require.IsType(t, &tenantToken{}, token)
+	assert.Equal(t, "tenant", token.(*tenantToken).TenantID)
+	assert.Equal(t, "acr", token.(*tenantToken).ACR)
+
+	clientStore, err := NewClientStore(
+		adapter,
+		WithClientStoreTableName(generateClientTableName()),
+		WithClientStoreCodec(GobCodec{}, newTenantClient),
+	)
+	require.NoError(t, err)
+
+	originalClient := &tenantClient{Client: models.Client{ID: fmt.Sprintf("id %s", time.Now().String())}, TenantID: "tenant"}
+	require.NoError(t, clientStore.Create(ctx, originalClient))
+
+	client, err := clientStore.GetByID(ctx, originalClient.GetID())
+	require.NoError(t, err)
+	assert.Equal(t, originalClient, client)
+}
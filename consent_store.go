@@ -0,0 +1,242 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// ConsentStore PostgreSQL store of the scopes users granted to clients
+type ConsentStore struct {
+	adapter        pgAdapter.Adapter
+	tableName      string
+	tokenTableName string
+	logger         Logger
+	tenantID       string
+	audit          auditLog
+
+	initTableDisabled bool
+
+	statements consentStatements
+}
+
+// Consent user consent data item, scope is space-delimited list of granted scopes
+type Consent struct {
+	ID        int64      `db:"id" json:"id"`
+	TenantID  string     `db:"tenant_id" json:"tenant_id"`
+	UserID    string     `db:"user_id" json:"user_id"`
+	ClientID  string     `db:"client_id" json:"client_id"`
+	Scope     string     `db:"scope" json:"scope"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+// consentColumns is the explicit consent table column list, matches consentRow fields
+const consentColumns = "id, tenant_id, user_id, client_id, scope, created_at, updated_at, expires_at, revoked_at"
+
+// consentRow is the consent table row
+type consentRow struct {
+	ID        int64        `db:"id"`
+	TenantID  string       `db:"tenant_id"`
+	UserID    string       `db:"user_id"`
+	ClientID  string       `db:"client_id"`
+	Scope     string       `db:"scope"`
+	CreatedAt time.Time    `db:"created_at"`
+	UpdatedAt time.Time    `db:"updated_at"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+func (r *consentRow) consent() *Consent {
+	return &Consent{
+		ID:        r.ID,
+		TenantID:  r.TenantID,
+		UserID:    r.UserID,
+		ClientID:  r.ClientID,
+		Scope:     r.Scope,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		ExpiresAt: nullTime(r.ExpiresAt),
+		RevokedAt: nullTime(r.RevokedAt),
+	}
+}
+
+// Covers checks if all the space-delimited scopes are granted by the consent
+func (c *Consent) Covers(scope string) bool {
+	granted := make(map[string]bool)
+	for _, s := range strings.Fields(c.Scope) {
+		granted[s] = true
+	}
+
+	for _, s := range strings.Fields(scope) {
+		if !granted[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// NewConsentStore creates PostgreSQL store instance
+func NewConsentStore(adapter pgAdapter.Adapter, options ...ConsentStoreOption) (*ConsentStore, error) {
+	store := &ConsentStore{
+		adapter:        adapter,
+		tableName:      "oauth2_consents",
+		tokenTableName: "oauth2_tokens",
+		logger:         log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		audit:          auditLog{tableName: DefaultAuditTableName},
+	}
+
+	for _, o := range options {
+		o(store)
+	}
+
+	store.statements = newConsentStatements(store.tableName, store.tokenTableName)
+
+	var err error
+	if !store.initTableDisabled {
+		err = store.initTable()
+	}
+
+	return store, err
+}
+
+// db returns the transaction adapter bound to the context or the store adapter
+func (s *ConsentStore) db(ctx context.Context) pgAdapter.Adapter {
+	return resolveAdapter(ctx, s.adapter)
+}
+
+func (s *ConsentStore) initTable() error {
+	ctx := context.Background()
+	err := s.adapter.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id         BIGSERIAL   NOT NULL,
+	user_id    TEXT        NOT NULL,
+	client_id  TEXT        NOT NULL,
+	scope      TEXT        NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_tenant_id_user_id_client_id ON %[1]s (tenant_id, user_id, client_id);
+DROP INDEX IF EXISTS idx_%[1]s_user_id_client_id;
+`, s.tableName))
+	if err == nil && s.audit.enabled {
+		err = initAuditTable(ctx, s.adapter, s.audit.tableName)
+	}
+	return err
+}
+
+// Grant grants the space-delimited scopes to the client on behalf of the user in the store or context tenant.
+// Scopes are added to the ones already granted by the active consent, revoked or expired consent is replaced.
+// Zero expiresIn means the consent never expires, otherwise consent expiration is reset on every grant.
+func (s *ConsentStore) Grant(ctx context.Context, userID, clientID, scope string, expiresIn time.Duration) error {
+	now := time.Now()
+
+	var expiresAt *time.Time
+	if expiresIn > 0 {
+		t := now.Add(expiresIn)
+		expiresAt = &t
+	}
+
+	return s.db(ctx).Exec(
+		ctx,
+		s.statements.grant,
+		userID,
+		clientID,
+		normalizeScope(scope),
+		now,
+		expiresAt,
+		resolveTenant(ctx, s.tenantID),
+	)
+}
+
+// Get returns active, i.e. not revoked and not expired, user consent for the client in the store or context tenant
+func (s *ConsentStore) Get(ctx context.Context, userID, clientID string) (*Consent, error) {
+	var item consentRow
+	if err := s.statements.get.selectOne(
+		ctx,
+		s.db(ctx),
+		&item,
+		userID,
+		clientID,
+		resolveTenant(ctx, s.tenantID),
+		time.Now(),
+	); err != nil {
+		return nil, err
+	}
+
+	return item.consent(), nil
+}
+
+// Covers checks if all the space-delimited scopes are already granted to the client by the user active consent
+func (s *ConsentStore) Covers(ctx context.Context, userID, clientID, scope string) (bool, error) {
+	consent, err := s.Get(ctx, userID, clientID)
+	if err == pgAdapter.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return consent.Covers(scope), nil
+}
+
+// List returns all active user consents in the store or context tenant
+func (s *ConsentStore) List(ctx context.Context, userID string) ([]*Consent, error) {
+	var items []*Consent
+	err := selectAll(
+		ctx,
+		s.db(ctx),
+		&items,
+		s.statements.list,
+		userID,
+		resolveTenant(ctx, s.tenantID),
+		time.Now(),
+	)
+	return items, err
+}
+
+// Revoke revokes user consent for the client in the store or context tenant and deletes all the tokens issued
+// to the client on behalf of the user in the same tenant
+func (s *ConsentStore) Revoke(ctx context.Context, userID, clientID string) error {
+	query, remove := s.statements.revoke, s.statements.removeTokens
+	args := []interface{}{userID, clientID, time.Now(), resolveTenant(ctx, s.tenantID)}
+
+	if !s.audit.enabled {
+		return s.db(ctx).Exec(ctx, query+"\n"+remove, args...)
+	}
+
+	audit, args, err := s.audit.insert(ctx, auditEntry{event: AuditTokenRemoved}, "changed", args)
+	if err != nil {
+		return err
+	}
+	query += fmt.Sprintf(", changed AS (%s RETURNING t.tenant_id, t.client_id, t.user_id, t.id AS token_id)\n%s", remove, audit)
+	return s.db(ctx).Exec(ctx, query, args...)
+}
+
+func normalizeScope(scope string) string {
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	sort.Strings(scopes)
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,56 @@
+package pg
+
+// ConsentStoreOption is the configuration options type for consent store
+type ConsentStoreOption func(s *ConsentStore)
+
+// WithConsentStoreTableName returns option that sets consent store table name
+func WithConsentStoreTableName(tableName string) ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.tableName = tableName
+	}
+}
+
+// WithConsentStoreTokenTableName returns option that sets the name of the token store table
+// consent store revokes tokens from
+func WithConsentStoreTokenTableName(tableName string) ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.tokenTableName = tableName
+	}
+}
+
+// WithConsentStoreTenantID returns option that scopes the tokens consent store revokes to the tenant,
+// tenant set to the operation context with WithTenant takes precedence
+func WithConsentStoreTenantID(tenantID string) ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithConsentStoreAudit returns option that enables audit log of the tokens removed on consent revocation,
+// audit records are written by the same statements as the changes
+func WithConsentStoreAudit() ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.audit.enabled = true
+	}
+}
+
+// WithConsentStoreAuditTableName returns option that sets audit log table name
+func WithConsentStoreAuditTableName(tableName string) ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.audit.tableName = tableName
+	}
+}
+
+// WithConsentStoreLogger returns option that sets consent store logger implementation
+func WithConsentStoreLogger(logger Logger) ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.logger = logger
+	}
+}
+
+// WithConsentStoreInitTableDisabled returns option that disables table creation on consent store instantiation
+func WithConsentStoreInitTableDisabled() ConsentStoreOption {
+	return func(s *ConsentStore) {
+		s.initTableDisabled = true
+	}
+}
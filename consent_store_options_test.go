@@ -0,0 +1,49 @@
+package pg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithConsentStoreInitTableDisabled(t *testing.T) {
+	store, err := NewConsentStore(nil, WithConsentStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.initTableDisabled)
+}
+
+func TestWithConsentStoreTableName(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewConsentStore(nil, WithConsentStoreTableName(randomName), WithConsentStoreTokenTableName(randomName+"_tokens"), WithConsentStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomName, store.tableName)
+	assert.Equal(t, randomName+"_tokens", store.tokenTableName)
+}
+
+func TestWithConsentStoreLogger(t *testing.T) {
+	l := new(memoryLogger)
+
+	store, err := NewConsentStore(nil, WithConsentStoreLogger(l), WithConsentStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, l, store.logger)
+}
+
+func TestWithConsentStoreTenantID(t *testing.T) {
+	randomTenant := time.Now().String()
+
+	store, err := NewConsentStore(nil, WithConsentStoreTenantID(randomTenant), WithConsentStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomTenant, store.tenantID)
+}
+
+func TestWithConsentStoreAudit(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewConsentStore(nil, WithConsentStoreAudit(), WithConsentStoreAuditTableName(randomName), WithConsentStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.audit.enabled)
+	assert.Equal(t, randomName, store.audit.tableName)
+}
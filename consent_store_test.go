@@ -0,0 +1,175 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+func TestConsentStore_initTable(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		query := args.Get(1).(string)
+		// new line character is the character at position 0
+		assert.Equal(t, 1, strings.Index(query, "CREATE TABLE IF NOT EXISTS"))
+	})
+
+	_, err := NewConsentStore(adapter)
+	require.NoError(t, err)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestConsent_Covers(t *testing.T) {
+	consent := &Consent{Scope: "email openid profile"}
+
+	assert.True(t, consent.Covers(""))
+	assert.True(t, consent.Covers("openid"))
+	assert.True(t, consent.Covers("profile  openid email"))
+	assert.False(t, consent.Covers("openid offline_access"))
+}
+
+func TestNormalizeScope(t *testing.T) {
+	assert.Equal(t, "", normalizeScope("  "))
+	assert.Equal(t, "email openid profile", normalizeScope("profile openid  email openid"))
+}
+
+func TestConsentStore(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := NewPGXPool(pool)
+	tokenTableName := generateTokenTableName()
+
+	tokenStore, err := NewTokenStore(adapter, WithTokenStoreTableName(tokenTableName), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	store, err := NewConsentStore(
+		adapter,
+		WithConsentStoreTableName(fmt.Sprintf("consent_%d", time.Now().UnixNano())),
+		WithConsentStoreTokenTableName(tokenTableName),
+		WithConsentStoreAudit(),
+		WithConsentStoreAuditTableName(tokenTableName+"_audit"),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	userID := fmt.Sprintf("user %s", time.Now().String())
+
+	covered, err := store.Covers(ctx, userID, "client", "openid")
+	require.NoError(t, err)
+	assert.False(t, covered)
+
+	require.NoError(t, store.Grant(ctx, userID, "client", "openid profile", 0))
+	require.NoError(t, store.Grant(ctx, userID, "client", "email openid", time.Hour))
+	require.NoError(t, store.Grant(ctx, userID, "other", "openid", 0))
+
+	covered, err = store.Covers(ctx, userID, "client", "openid email profile")
+	require.NoError(t, err)
+	assert.True(t, covered)
+
+	covered, err = store.Covers(ctx, userID, "client", "offline_access")
+	require.NoError(t, err)
+	assert.False(t, covered)
+
+	consents, err := store.List(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, consents, 2)
+	assert.Equal(t, "client", consents[0].ClientID)
+	assert.Equal(t, "email openid profile", consents[0].Scope)
+	assert.NotNil(t, consents[0].ExpiresAt)
+	assert.Equal(t, "other", consents[1].ClientID)
+	assert.Nil(t, consents[1].ExpiresAt)
+
+	access := fmt.Sprintf("access %s", time.Now().String())
+	token := models.NewToken()
+	token.SetClientID("client")
+	token.SetUserID(userID)
+	token.SetAccess(access)
+	token.SetAccessCreateAt(time.Now())
+	token.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, tokenStore.Create(ctx, token))
+
+	// token of the same user and client in another tenant is kept
+	tenantCtx := WithTenant(ctx, "tenant")
+	tenantToken := models.NewToken()
+	tenantToken.SetClientID("client")
+	tenantToken.SetUserID(userID)
+	tenantToken.SetAccess(access + " tenant")
+	tenantToken.SetAccessCreateAt(time.Now())
+	tenantToken.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, tokenStore.Create(tenantCtx, tenantToken))
+
+	require.NoError(t, store.Revoke(WithAuditInfo(ctx, AuditInfo{Actor: userID}), userID, "client"))
+
+	_, err = store.Get(ctx, userID, "client")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	_, err = tokenStore.GetByAccess(ctx, access)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	_, err = tokenStore.GetByAccess(tenantCtx, tenantToken.GetAccess())
+	assert.NoError(t, err)
+
+	records, err := auditStoreFor(t, adapter, tokenTableName+"_audit").Query(ctx, AuditQuery{UserID: userID})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, AuditTokenRemoved, records[0].Event)
+	assert.Equal(t, "client", records[0].ClientID)
+	assert.Equal(t, "", records[0].TenantID)
+	assert.Equal(t, userID, records[0].Actor)
+
+	require.NoError(t, store.Grant(ctx, userID, "client", "email", 0))
+	consent, err := store.Get(ctx, userID, "client")
+	require.NoError(t, err)
+	assert.Equal(t, "email", consent.Scope)
+	assert.Nil(t, consent.RevokedAt)
+
+	// consents are kept per tenant
+	require.NoError(t, store.Grant(tenantCtx, userID, "client", "openid", 0))
+	consent, err = store.Get(tenantCtx, userID, "client")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant", consent.TenantID)
+	assert.Equal(t, "openid", consent.Scope)
+
+	consent, err = store.Get(ctx, userID, "client")
+	require.NoError(t, err)
+	assert.Equal(t, "", consent.TenantID)
+	assert.Equal(t, "email", consent.Scope)
+
+	// client and user columns of the tokens created before the columns were added are filled on table init
+	legacy := models.NewToken()
+	legacy.SetClientID("client")
+	legacy.SetUserID(userID)
+	legacy.SetAccess(access + " legacy")
+	legacy.SetAccessCreateAt(time.Now())
+	legacy.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, tokenStore.Create(ctx, legacy))
+
+	_, err = pool.Exec(ctx, fmt.Sprintf("UPDATE %s SET client_id = '', user_id = '' WHERE access = $1", tokenTableName), legacy.GetAccess())
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, fmt.Sprintf("COMMENT ON TABLE %s IS NULL", tokenTableName))
+	require.NoError(t, err)
+
+	_, err = NewTokenStore(adapter, WithTokenStoreTableName(tokenTableName), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(ctx, userID, "client"))
+	_, err = tokenStore.GetByAccess(ctx, legacy.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
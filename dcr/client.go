@@ -0,0 +1,222 @@
+package dcr
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// Token endpoint authentication methods, see RFC 7591 section 2
+const (
+	AuthMethodNone              = "none"
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodClientSecretJWT   = "client_secret_jwt"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+)
+
+// Client is the dynamically registered client information with RFC 7591 metadata,
+// it implements pg.ClientMetadata, so it can be stored in and loaded from pg.ClientStore
+// configured with NewClientInfo factory.
+type Client struct {
+	ClientID              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt      int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt int64  `json:"client_secret_expires_at"`
+
+	RedirectURIs            []string        `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string        `json:"grant_types,omitempty"`
+	ResponseTypes           []string        `json:"response_types,omitempty"`
+	ClientName              string          `json:"client_name,omitempty"`
+	ClientURI               string          `json:"client_uri,omitempty"`
+	LogoURI                 string          `json:"logo_uri,omitempty"`
+	Scope                   string          `json:"scope,omitempty"`
+	Contacts                []string        `json:"contacts,omitempty"`
+	TOSURI                  string          `json:"tos_uri,omitempty"`
+	PolicyURI               string          `json:"policy_uri,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty"`
+	SoftwareID              string          `json:"software_id,omitempty"`
+	SoftwareVersion         string          `json:"software_version,omitempty"`
+
+	// RegistrationAccessTokenHash is SHA-256 hash of the registration access token, never returned to the client
+	RegistrationAccessTokenHash string `json:"registration_access_token_hash,omitempty"`
+}
+
+// NewClientInfo is the pg.ClientInfoFactory for the registered clients
+func NewClientInfo() oauth2.ClientInfo {
+	return &Client{}
+}
+
+// GetID returns client id
+func (c *Client) GetID() string {
+	return c.ClientID
+}
+
+// GetSecret returns client secret
+func (c *Client) GetSecret() string {
+	return c.ClientSecret
+}
+
+// GetDomain returns the first redirect URI, oauth2 server checks redirect URI against it
+func (c *Client) GetDomain() string {
+	if len(c.RedirectURIs) == 0 {
+		return ""
+	}
+	return c.RedirectURIs[0]
+}
+
+// IsPublic returns true for the clients that do not authenticate at the token endpoint
+func (c *Client) IsPublic() bool {
+	return c.TokenEndpointAuthMethod == AuthMethodNone
+}
+
+// GetUserID returns empty string as registered clients are not bound to users
+func (c *Client) GetUserID() string {
+	return ""
+}
+
+// GetRedirectURIs returns registered redirect URIs
+func (c *Client) GetRedirectURIs() []string {
+	return c.RedirectURIs
+}
+
+// GetGrantTypes returns registered grant types
+func (c *Client) GetGrantTypes() []string {
+	return c.GrantTypes
+}
+
+// GetScopes returns registered scopes
+func (c *Client) GetScopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// GetAccessTokenLifetime returns zero as registered clients use server default lifetimes
+func (c *Client) GetAccessTokenLifetime() time.Duration {
+	return 0
+}
+
+// GetRefreshTokenLifetime returns zero as registered clients use server default lifetimes
+func (c *Client) GetRefreshTokenLifetime() time.Duration {
+	return 0
+}
+
+// metadataError is RFC 7591 section 3.2.2 client registration error
+type metadataError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *metadataError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func invalidMetadata(description string) *metadataError {
+	return &metadataError{Code: "invalid_client_metadata", Description: description}
+}
+
+func invalidRedirectURI(description string) *metadataError {
+	return &metadataError{Code: "invalid_redirect_uri", Description: description}
+}
+
+// validate checks client metadata, sets defaults for omitted values and returns registration error if any
+func (h *Handler) validate(c *Client) *metadataError {
+	if c.TokenEndpointAuthMethod == "" {
+		c.TokenEndpointAuthMethod = AuthMethodClientSecretBasic
+	}
+	if !contains(h.authMethods, c.TokenEndpointAuthMethod) {
+		return invalidMetadata("unsupported token_endpoint_auth_method " + c.TokenEndpointAuthMethod)
+	}
+
+	if len(c.GrantTypes) == 0 {
+		c.GrantTypes = []string{"authorization_code"}
+	}
+	for _, gt := range c.GrantTypes {
+		if !contains(h.grantTypes, gt) {
+			return invalidMetadata("unsupported grant type " + gt)
+		}
+	}
+
+	if len(c.ResponseTypes) == 0 && contains(c.GrantTypes, "authorization_code") {
+		c.ResponseTypes = []string{"code"}
+	}
+	for _, rt := range c.ResponseTypes {
+		switch {
+		case rt == "code" && contains(c.GrantTypes, "authorization_code"):
+		case rt == "token" && contains(c.GrantTypes, "implicit"):
+		default:
+			return invalidMetadata("response type " + rt + " does not match grant types")
+		}
+	}
+
+	if err := validateRedirectURIs(c); err != nil {
+		return err
+	}
+
+	if h.scopes != nil {
+		for _, s := range strings.Fields(c.Scope) {
+			if !contains(h.scopes, s) {
+				return invalidMetadata("scope " + s + " is not allowed")
+			}
+		}
+	}
+
+	return validateJWKS(c)
+}
+
+func validateRedirectURIs(c *Client) *metadataError {
+	if len(c.RedirectURIs) == 0 && (contains(c.GrantTypes, "authorization_code") || contains(c.GrantTypes, "implicit")) {
+		return invalidRedirectURI("redirect_uris are required for redirect-based grant types")
+	}
+
+	for _, u := range c.RedirectURIs {
+		parsed, err := url.Parse(u)
+		if err != nil || !parsed.IsAbs() || (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host == "" {
+			return invalidRedirectURI("redirect uri " + u + " must be an absolute URI")
+		}
+		if parsed.Fragment != "" || strings.Contains(u, "#") {
+			return invalidRedirectURI("redirect uri " + u + " must not contain fragment")
+		}
+	}
+	return nil
+}
+
+func validateJWKS(c *Client) *metadataError {
+	if c.JWKSURI != "" && len(c.JWKS) > 0 {
+		return invalidMetadata("jwks_uri and jwks must not be both present")
+	}
+
+	if c.JWKSURI != "" {
+		parsed, err := url.Parse(c.JWKSURI)
+		if err != nil || parsed.Scheme != "https" {
+			return invalidMetadata("jwks_uri must be an https URL")
+		}
+	}
+
+	if len(c.JWKS) > 0 {
+		var set struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(c.JWKS, &set); err != nil || len(set.Keys) == 0 {
+			return invalidMetadata("jwks must be a JWK Set with keys")
+		}
+	}
+
+	if c.TokenEndpointAuthMethod == AuthMethodPrivateKeyJWT && c.JWKSURI == "" && len(c.JWKS) == 0 {
+		return invalidMetadata("private_key_jwt requires jwks_uri or jwks")
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
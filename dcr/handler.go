@@ -0,0 +1,331 @@
+// Package dcr implements OAuth 2.0 Dynamic Client Registration Protocol (RFC 7591)
+// and Dynamic Client Registration Management Protocol (RFC 7592) endpoints backed by pg.ClientStore.
+package dcr
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+
+	pg "github.com/vgarvardt/go-oauth2-pg/v4"
+)
+
+// maxBodySize is the maximum accepted client metadata request body size
+const maxBodySize = 1 << 20
+
+// InitialAccessTokenValidator checks the initial access token presented on client registration,
+// non-nil error rejects the registration
+type InitialAccessTokenValidator func(ctx context.Context, token string) error
+
+// Handler serves client registration endpoint at the registration URL path and client configuration
+// endpoint at the registration URL path followed by the client id
+type Handler struct {
+	store           *pg.ClientStore
+	registrationURL *url.URL
+	logger          pg.Logger
+
+	grantTypes  []string
+	authMethods []string
+	scopes      []string
+
+	initialAccessTokenValidator InitialAccessTokenValidator
+}
+
+type clientResponse struct {
+	*Client
+
+	// shadows embedded client field, so that it is never returned
+	RegistrationAccessTokenHash string `json:"registration_access_token_hash,omitempty"`
+
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+}
+
+// NewHandler creates registration handler instance, registrationURL is the absolute client registration
+// endpoint URL. Client store must be configured with NewClientInfo factory.
+func NewHandler(store *pg.ClientStore, registrationURL string, options ...Option) (*Handler, error) {
+	u, err := url.Parse(registrationURL)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		store:           store,
+		registrationURL: u,
+		logger:          log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		grantTypes:      []string{"authorization_code", "refresh_token", "client_credentials", "implicit"},
+		authMethods:     []string{AuthMethodNone, AuthMethodClientSecretBasic, AuthMethodClientSecretPost},
+	}
+
+	for _, o := range options {
+		o(h)
+	}
+
+	return h, nil
+}
+
+// ServeHTTP routes registration and client configuration requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := strings.TrimSuffix(h.registrationURL.Path, "/")
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == base:
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.register(w, r)
+	case strings.HasPrefix(path, base+"/") && !strings.Contains(path[len(base)+1:], "/"):
+		clientID := path[len(base)+1:]
+		switch r.Method {
+		case http.MethodGet:
+			h.read(w, r, clientID)
+		case http.MethodPut:
+			h.update(w, r, clientID)
+		case http.MethodDelete:
+			h.delete(w, r, clientID)
+		default:
+			w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	if h.initialAccessTokenValidator != nil {
+		token := bearerToken(r)
+		if token == "" || h.initialAccessTokenValidator(r.Context(), token) != nil {
+			writeUnauthorized(w)
+			return
+		}
+	}
+
+	client, err := decodeClient(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, invalidMetadata(err.Error()))
+		return
+	}
+	client.ClientID = ""
+	client.ClientSecret = ""
+	client.RegistrationAccessTokenHash = ""
+
+	if mErr := h.validate(client); mErr != nil {
+		writeError(w, http.StatusBadRequest, mErr)
+		return
+	}
+
+	if client.ClientID, err = randomString(16); err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+	if client.TokenEndpointAuthMethod != AuthMethodNone {
+		if client.ClientSecret, err = randomString(32); err != nil {
+			h.writeServerError(w, err)
+			return
+		}
+	}
+	client.ClientIDIssuedAt = time.Now().Unix()
+
+	registrationAccessToken, err := randomString(32)
+	if err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+	client.RegistrationAccessTokenHash = hashToken(registrationAccessToken)
+
+	if err := h.store.Create(r.Context(), client); err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+
+	h.writeClient(w, http.StatusCreated, client, registrationAccessToken)
+}
+
+func (h *Handler) read(w http.ResponseWriter, r *http.Request, clientID string) {
+	client, err := h.authenticate(r, clientID)
+	if err != nil {
+		h.writeAuthError(w, err)
+		return
+	}
+
+	h.writeClient(w, http.StatusOK, client, "")
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, clientID string) {
+	client, err := h.authenticate(r, clientID)
+	if err != nil {
+		h.writeAuthError(w, err)
+		return
+	}
+
+	updated, err := decodeClient(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, invalidMetadata(err.Error()))
+		return
+	}
+	if updated.ClientID != client.ClientID {
+		writeError(w, http.StatusBadRequest, invalidMetadata("client_id does not match"))
+		return
+	}
+	if updated.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(updated.ClientSecret), []byte(client.ClientSecret)) != 1 {
+		writeError(w, http.StatusBadRequest, invalidMetadata("client_secret does not match"))
+		return
+	}
+
+	if mErr := h.validate(updated); mErr != nil {
+		writeError(w, http.StatusBadRequest, mErr)
+		return
+	}
+
+	updated.ClientSecret = client.ClientSecret
+	if updated.TokenEndpointAuthMethod == AuthMethodNone {
+		updated.ClientSecret = ""
+	} else if updated.ClientSecret == "" {
+		if updated.ClientSecret, err = randomString(32); err != nil {
+			h.writeServerError(w, err)
+			return
+		}
+	}
+	updated.ClientIDIssuedAt = client.ClientIDIssuedAt
+	updated.ClientSecretExpiresAt = client.ClientSecretExpiresAt
+	updated.RegistrationAccessTokenHash = client.RegistrationAccessTokenHash
+
+	if err := h.store.Update(r.Context(), updated); err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+
+	h.writeClient(w, http.StatusOK, updated, "")
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, clientID string) {
+	if _, err := h.authenticate(r, clientID); err != nil {
+		h.writeAuthError(w, err)
+		return
+	}
+
+	if err := h.store.RemoveByID(r.Context(), clientID); err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errUnauthorized = errors.New("unauthorized")
+
+// authenticate loads registered client and checks registration access token presented in the request
+func (h *Handler) authenticate(r *http.Request, clientID string) (*Client, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, errUnauthorized
+	}
+
+	info, err := h.store.GetByID(r.Context(), clientID)
+	if err == pgAdapter.ErrNoRows || err == pg.ErrClientDisabled {
+		// RFC 7592 section 2: do not reveal that the client does not exist
+		return nil, errUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, ok := pg.UnwrapClient(info).(*Client)
+	if !ok || client.RegistrationAccessTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(client.RegistrationAccessTokenHash), []byte(hashToken(token))) != 1 {
+		return nil, errUnauthorized
+	}
+
+	return client, nil
+}
+
+func (h *Handler) registrationClientURI(clientID string) string {
+	u := *h.registrationURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + clientID
+	return u.String()
+}
+
+func (h *Handler) writeClient(w http.ResponseWriter, status int, client *Client, registrationAccessToken string) {
+	writeJSON(w, status, clientResponse{
+		Client:                  client,
+		RegistrationAccessToken: registrationAccessToken,
+		RegistrationClientURI:   h.registrationClientURI(client.ClientID),
+	})
+}
+
+func (h *Handler) writeAuthError(w http.ResponseWriter, err error) {
+	if err == errUnauthorized {
+		writeUnauthorized(w)
+		return
+	}
+	h.writeServerError(w, err)
+}
+
+func (h *Handler) writeServerError(w http.ResponseWriter, err error) {
+	h.logger.Printf("Error while handling client registration request: %+v", err)
+	writeError(w, http.StatusInternalServerError, &metadataError{Code: "server_error", Description: "internal server error"})
+}
+
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	writeError(w, http.StatusUnauthorized, &metadataError{Code: "invalid_token", Description: "access token is missing or invalid"})
+}
+
+func writeError(w http.ResponseWriter, status int, err *metadataError) {
+	writeJSON(w, status, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func decodeClient(r *http.Request) (*Client, error) {
+	var client Client
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
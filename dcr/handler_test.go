@@ -0,0 +1,157 @@
+package dcr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+
+	pg "github.com/vgarvardt/go-oauth2-pg/v4"
+)
+
+var uri string
+
+func TestMain(m *testing.M) {
+	uri = os.Getenv("PG_URI")
+	if uri == "" {
+		fmt.Println("Env variable PG_URI is required to run the tests")
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestHandler_validate(t *testing.T) {
+	h, err := NewHandler(nil, "https://as.example.com/register", WithScopes("openid", "profile"))
+	require.NoError(t, err)
+
+	client := &Client{RedirectURIs: []string{"https://client.example.com/cb"}, Scope: "openid"}
+	require.Nil(t, h.validate(client))
+	assert.Equal(t, AuthMethodClientSecretBasic, client.TokenEndpointAuthMethod)
+	assert.Equal(t, []string{"authorization_code"}, client.GrantTypes)
+	assert.Equal(t, []string{"code"}, client.ResponseTypes)
+
+	for name, tc := range map[string]struct {
+		client *Client
+		code   string
+	}{
+		"missing redirect uri":   {&Client{}, "invalid_redirect_uri"},
+		"relative redirect uri":  {&Client{RedirectURIs: []string{"/cb"}}, "invalid_redirect_uri"},
+		"fragment redirect uri":  {&Client{RedirectURIs: []string{"https://client.example.com/cb#foo"}}, "invalid_redirect_uri"},
+		"unknown grant type":     {&Client{GrantTypes: []string{"password"}}, "invalid_client_metadata"},
+		"unknown auth method":    {&Client{TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT}, "invalid_client_metadata"},
+		"mismatch response type": {&Client{GrantTypes: []string{"client_credentials"}, ResponseTypes: []string{"code"}}, "invalid_client_metadata"},
+		"not allowed scope":      {&Client{GrantTypes: []string{"client_credentials"}, Scope: "admin"}, "invalid_client_metadata"},
+		"jwks and jwks uri": {&Client{
+			GrantTypes: []string{"client_credentials"},
+			JWKSURI:    "https://client.example.com/jwks",
+			JWKS:       json.RawMessage(`{"keys":[{"kty":"EC"}]}`),
+		}, "invalid_client_metadata"},
+		"invalid jwks": {&Client{GrantTypes: []string{"client_credentials"}, JWKS: json.RawMessage(`{"keys":[]}`)}, "invalid_client_metadata"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mErr := h.validate(tc.client)
+			require.NotNil(t, mErr)
+			assert.Equal(t, tc.code, mErr.Code)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	store, err := pg.NewClientStore(
+		pgx4adapter.NewPool(pool),
+		pg.WithClientStoreTableName(fmt.Sprintf("client_dcr_%d", time.Now().UnixNano())),
+		pg.WithClientStoreCodec(pg.JSONCodec{}, NewClientInfo),
+	)
+	require.NoError(t, err)
+
+	h, err := NewHandler(store, "https://as.example.com/register", WithInitialAccessTokenValidator(func(ctx context.Context, token string) error {
+		if token != "initial" {
+			return errors.New("invalid initial access token")
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+
+	do := func(method, target, token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+
+		r := httptest.NewRequest(method, target, &buf)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	metadata := map[string]interface{}{
+		"redirect_uris": []string{"https://client.example.com/cb"},
+		"client_name":   "Test Client",
+		"scope":         "openid",
+	}
+
+	w := do(http.MethodPost, "/register", "", metadata)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(http.MethodPost, "/register", "initial", metadata)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var registered map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&registered))
+	clientID := registered["client_id"].(string)
+	registrationAccessToken := registered["registration_access_token"].(string)
+	assert.NotEmpty(t, registered["client_secret"])
+	assert.Equal(t, "https://as.example.com/register/"+clientID, registered["registration_client_uri"])
+	assert.NotContains(t, registered, "registration_access_token_hash")
+
+	info, err := store.GetByID(context.Background(), clientID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://client.example.com/cb", info.GetDomain())
+
+	w = do(http.MethodGet, "/register/"+clientID, "wrong", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(http.MethodGet, "/register/"+clientID, registrationAccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var read map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&read))
+	assert.Equal(t, "Test Client", read["client_name"])
+	assert.NotContains(t, read, "registration_access_token")
+
+	metadata["client_id"] = clientID
+	metadata["client_name"] = "Updated Client"
+	w = do(http.MethodPut, "/register/"+clientID, registrationAccessToken, metadata)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var updated map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+	assert.Equal(t, "Updated Client", updated["client_name"])
+	assert.Equal(t, registered["client_secret"], updated["client_secret"])
+
+	w = do(http.MethodDelete, "/register/"+clientID, registrationAccessToken, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = do(http.MethodGet, "/register/"+clientID, registrationAccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
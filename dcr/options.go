@@ -0,0 +1,43 @@
+package dcr
+
+import pg "github.com/vgarvardt/go-oauth2-pg/v4"
+
+// Option is the configuration options type for registration handler
+type Option func(h *Handler)
+
+// WithLogger returns option that sets registration handler logger implementation
+func WithLogger(logger pg.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithGrantTypes returns option that sets grant types clients are allowed to register with
+func WithGrantTypes(grantTypes ...string) Option {
+	return func(h *Handler) {
+		h.grantTypes = grantTypes
+	}
+}
+
+// WithTokenEndpointAuthMethods returns option that sets token endpoint authentication methods
+// clients are allowed to register with
+func WithTokenEndpointAuthMethods(authMethods ...string) Option {
+	return func(h *Handler) {
+		h.authMethods = authMethods
+	}
+}
+
+// WithScopes returns option that sets scopes clients are allowed to register with, any scope is allowed by default
+func WithScopes(scopes ...string) Option {
+	return func(h *Handler) {
+		h.scopes = scopes
+	}
+}
+
+// WithInitialAccessTokenValidator returns option that requires initial access token for client registration,
+// registration is open by default
+func WithInitialAccessTokenValidator(validator InitialAccessTokenValidator) Option {
+	return func(h *Handler) {
+		h.initialAccessTokenValidator = validator
+	}
+}
@@ -0,0 +1,301 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// DeviceCodeStatus is the device authorization request status
+type DeviceCodeStatus string
+
+// Device authorization request statuses
+const (
+	DeviceCodeStatusPending  DeviceCodeStatus = "pending"
+	DeviceCodeStatusApproved DeviceCodeStatus = "approved"
+	DeviceCodeStatusDenied   DeviceCodeStatus = "denied"
+)
+
+// DefaultDeviceCodeInterval is the default minimal polling interval, in seconds, when none is set on creation
+const DefaultDeviceCodeInterval = 5
+
+// slowDownIncrement is the number of seconds polling interval is increased by on slow_down, see RFC 8628 section 3.5
+const slowDownIncrement = 5
+
+// Device access token request errors, see RFC 8628 section 3.5
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+)
+
+// DeviceCodeStore PostgreSQL device authorization grant (RFC 8628) store
+type DeviceCodeStore struct {
+	adapter   pgAdapter.Adapter
+	tableName string
+	logger    Logger
+	tenantID  string
+
+	gcDisabled bool
+	gcInterval time.Duration
+	ticker     *time.Ticker
+
+	initTableDisabled bool
+
+	statements deviceCodeStatements
+}
+
+// DeviceCode device authorization request data item
+type DeviceCode struct {
+	ID           int64            `db:"id"`
+	TenantID     string           `db:"tenant_id"`
+	CreatedAt    time.Time        `db:"created_at"`
+	ExpiresAt    time.Time        `db:"expires_at"`
+	DeviceCode   string           `db:"device_code"`
+	UserCode     string           `db:"user_code"`
+	ClientID     string           `db:"client_id"`
+	Scope        string           `db:"scope"`
+	Interval     int              `db:"poll_interval"`
+	Status       DeviceCodeStatus `db:"status"`
+	UserID       string           `db:"user_id"`
+	LastPolledAt *time.Time       `db:"last_polled_at"`
+}
+
+// deviceCodeColumns is the explicit device code table column list, matches deviceCodeRow fields
+const deviceCodeColumns = "id, tenant_id, created_at, expires_at, device_code, user_code, client_id, scope, poll_interval, status, user_id, last_polled_at"
+
+// deviceCodeRow is the device code table row, slow_down is set by the poll only
+type deviceCodeRow struct {
+	ID           int64            `db:"id"`
+	TenantID     string           `db:"tenant_id"`
+	CreatedAt    time.Time        `db:"created_at"`
+	ExpiresAt    time.Time        `db:"expires_at"`
+	DeviceCode   string           `db:"device_code"`
+	UserCode     string           `db:"user_code"`
+	ClientID     string           `db:"client_id"`
+	Scope        string           `db:"scope"`
+	Interval     int              `db:"poll_interval"`
+	Status       DeviceCodeStatus `db:"status"`
+	UserID       string           `db:"user_id"`
+	LastPolledAt sql.NullTime     `db:"last_polled_at"`
+	SlowDown     bool             `db:"slow_down"`
+}
+
+func (r *deviceCodeRow) deviceCode() *DeviceCode {
+	return &DeviceCode{
+		ID:           r.ID,
+		TenantID:     r.TenantID,
+		CreatedAt:    r.CreatedAt,
+		ExpiresAt:    r.ExpiresAt,
+		DeviceCode:   r.DeviceCode,
+		UserCode:     r.UserCode,
+		ClientID:     r.ClientID,
+		Scope:        r.Scope,
+		Interval:     r.Interval,
+		Status:       r.Status,
+		UserID:       r.UserID,
+		LastPolledAt: nullTime(r.LastPolledAt),
+	}
+}
+
+// NewDeviceCodeStore creates PostgreSQL store instance
+func NewDeviceCodeStore(adapter pgAdapter.Adapter, options ...DeviceCodeStoreOption) (*DeviceCodeStore, error) {
+	store := &DeviceCodeStore{
+		adapter:    adapter,
+		tableName:  "oauth2_device_codes",
+		logger:     log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		gcInterval: 10 * time.Minute,
+	}
+
+	for _, o := range options {
+		o(store)
+	}
+
+	store.statements = newDeviceCodeStatements(store.tableName)
+
+	var err error
+	if !store.initTableDisabled {
+		err = store.initTable()
+	}
+
+	if err != nil {
+		return store, err
+	}
+
+	if !store.gcDisabled {
+		store.ticker = time.NewTicker(store.gcInterval)
+		go store.gc()
+	}
+
+	return store, err
+}
+
+// Close closes the store
+func (s *DeviceCodeStore) Close() error {
+	if !s.gcDisabled {
+		s.ticker.Stop()
+	}
+	return nil
+}
+
+func (s *DeviceCodeStore) gc() {
+	for range s.ticker.C {
+		s.clean()
+	}
+}
+
+// db returns the transaction adapter bound to the context or the store adapter
+func (s *DeviceCodeStore) db(ctx context.Context) pgAdapter.Adapter {
+	return resolveAdapter(ctx, s.adapter)
+}
+
+func (s *DeviceCodeStore) initTable() error {
+	return s.adapter.Exec(context.Background(), fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id             BIGSERIAL   NOT NULL,
+	created_at     TIMESTAMPTZ NOT NULL,
+	expires_at     TIMESTAMPTZ NOT NULL,
+	device_code    TEXT        NOT NULL,
+	user_code      TEXT        NOT NULL,
+	client_id      TEXT        NOT NULL,
+	scope          TEXT        NOT NULL,
+	poll_interval  INTEGER     NOT NULL,
+	status         TEXT        NOT NULL,
+	user_id        TEXT        NOT NULL,
+	last_polled_at TIMESTAMPTZ,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_device_code ON %[1]s (device_code);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_user_code ON %[1]s (user_code);
+CREATE INDEX IF NOT EXISTS idx_%[1]s_expires_at ON %[1]s (expires_at);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+`, s.tableName))
+}
+
+func (s *DeviceCodeStore) clean() {
+	now := time.Now()
+	err := s.adapter.Exec(context.Background(), s.statements.clean, now)
+	if err != nil {
+		s.logger.Printf("Error while cleaning out outdated entities: %+v", err)
+	}
+}
+
+// Create stores the new pending device authorization request in the store or context tenant.
+// Creation time and polling interval get default values when not set.
+func (s *DeviceCodeStore) Create(ctx context.Context, dc *DeviceCode) error {
+	if dc.CreatedAt.IsZero() {
+		dc.CreatedAt = time.Now()
+	}
+	if dc.Interval <= 0 {
+		dc.Interval = DefaultDeviceCodeInterval
+	}
+	dc.Status = DeviceCodeStatusPending
+	dc.TenantID = resolveTenant(ctx, s.tenantID)
+
+	return s.db(ctx).Exec(
+		ctx,
+		s.statements.create,
+		dc.TenantID,
+		dc.CreatedAt,
+		dc.ExpiresAt,
+		dc.DeviceCode,
+		dc.UserCode,
+		dc.ClientID,
+		dc.Scope,
+		dc.Interval,
+		dc.Status,
+		dc.UserID,
+	)
+}
+
+// GetByDeviceCode returns device authorization request by device code
+func (s *DeviceCodeStore) GetByDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	var item deviceCodeRow
+	if err := s.statements.getByDeviceCode.selectOne(ctx, s.db(ctx), &item, deviceCode, resolveTenant(ctx, s.tenantID)); err != nil {
+		return nil, err
+	}
+
+	return item.deviceCode(), nil
+}
+
+// GetByUserCode returns device authorization request by user code
+func (s *DeviceCodeStore) GetByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	var item deviceCodeRow
+	if err := s.statements.getByUserCode.selectOne(ctx, s.db(ctx), &item, userCode, resolveTenant(ctx, s.tenantID)); err != nil {
+		return nil, err
+	}
+
+	return item.deviceCode(), nil
+}
+
+// Approve approves pending not expired device authorization request identified by user code and binds it to the user.
+// Returns pgAdapter.ErrNoRows if there is no such request.
+func (s *DeviceCodeStore) Approve(ctx context.Context, userCode, userID string) error {
+	return s.resolve(ctx, userCode, DeviceCodeStatusApproved, userID)
+}
+
+// Deny denies pending not expired device authorization request identified by user code.
+// Returns pgAdapter.ErrNoRows if there is no such request.
+func (s *DeviceCodeStore) Deny(ctx context.Context, userCode string) error {
+	return s.resolve(ctx, userCode, DeviceCodeStatusDenied, "")
+}
+
+func (s *DeviceCodeStore) resolve(ctx context.Context, userCode string, status DeviceCodeStatus, userID string) error {
+	var item deviceCodeRow
+	return s.db(ctx).SelectOne(
+		ctx,
+		&item,
+		s.statements.resolve,
+		status,
+		userID,
+		userCode,
+		resolveTenant(ctx, s.tenantID),
+		DeviceCodeStatusPending,
+		time.Now(),
+	)
+}
+
+// Poll registers device access token request for the device code and returns approved device authorization request.
+// Approved request is removed by the same statement, so that it is returned once only, even to the concurrent polls.
+// Polling faster than the request interval increases the interval and results in ErrSlowDown.
+// Other possible errors are ErrAuthorizationPending, ErrAccessDenied, ErrExpiredToken
+// and pgAdapter.ErrNoRows for unknown or already consumed device code.
+func (s *DeviceCodeStore) Poll(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	now := time.Now()
+
+	var item deviceCodeRow
+	if err := s.db(ctx).SelectOne(ctx, &item, s.statements.poll, deviceCode, now, slowDownIncrement, resolveTenant(ctx, s.tenantID), DeviceCodeStatusApproved); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !item.ExpiresAt.After(now):
+		return nil, ErrExpiredToken
+	case item.Status == DeviceCodeStatusDenied:
+		return nil, ErrAccessDenied
+	case item.Status == DeviceCodeStatusApproved:
+		return item.deviceCode(), nil
+	case item.SlowDown:
+		return nil, ErrSlowDown
+	default:
+		return nil, ErrAuthorizationPending
+	}
+}
+
+// RemoveByDeviceCode deletes device authorization request, e.g. when the device flow is cancelled
+func (s *DeviceCodeStore) RemoveByDeviceCode(ctx context.Context, deviceCode string) error {
+	err := s.db(ctx).Exec(ctx, s.statements.remove, deviceCode, resolveTenant(ctx, s.tenantID))
+	if err == pgAdapter.ErrNoRows {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package pg
+
+import "time"
+
+// DeviceCodeStoreOption is the configuration options type for device code store
+type DeviceCodeStoreOption func(s *DeviceCodeStore)
+
+// WithDeviceCodeStoreTableName returns option that sets device code store table name
+func WithDeviceCodeStoreTableName(tableName string) DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.tableName = tableName
+	}
+}
+
+// WithDeviceCodeStoreGCInterval returns option that sets device code store garbage collection interval
+func WithDeviceCodeStoreGCInterval(gcInterval time.Duration) DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.gcInterval = gcInterval
+	}
+}
+
+// WithDeviceCodeStoreLogger returns option that sets device code store logger implementation
+func WithDeviceCodeStoreLogger(logger Logger) DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.logger = logger
+	}
+}
+
+// WithDeviceCodeStoreTenantID returns option that scopes device code store to the tenant,
+// tenant set to the operation context with WithTenant takes precedence
+func WithDeviceCodeStoreTenantID(tenantID string) DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithDeviceCodeStoreGCDisabled returns option that disables device code store garbage collection
+func WithDeviceCodeStoreGCDisabled() DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.gcDisabled = true
+	}
+}
+
+// WithDeviceCodeStoreInitTableDisabled returns option that disables table creation on device code store instantiation
+func WithDeviceCodeStoreInitTableDisabled() DeviceCodeStoreOption {
+	return func(s *DeviceCodeStore) {
+		s.initTableDisabled = true
+	}
+}
@@ -0,0 +1,53 @@
+package pg
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDeviceCodeStoreGCDisabled(t *testing.T) {
+	store, err := NewDeviceCodeStore(nil, WithDeviceCodeStoreGCDisabled(), WithDeviceCodeStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.gcDisabled)
+	assert.True(t, store.initTableDisabled)
+}
+
+func TestWithDeviceCodeStoreTableName(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewDeviceCodeStore(nil, WithDeviceCodeStoreTableName(randomName), WithDeviceCodeStoreGCDisabled(), WithDeviceCodeStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomName, store.tableName)
+}
+
+func TestWithDeviceCodeStoreGCInterval(t *testing.T) {
+	randomInterval := time.Duration(rand.Int63())
+
+	store, err := NewDeviceCodeStore(nil, WithDeviceCodeStoreGCInterval(randomInterval), WithDeviceCodeStoreGCDisabled(), WithDeviceCodeStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomInterval, store.gcInterval)
+}
+
+func TestWithDeviceCodeStoreTenantID(t *testing.T) {
+	randomTenant := time.Now().String()
+
+	store, err := NewDeviceCodeStore(nil, WithDeviceCodeStoreTenantID(randomTenant), WithDeviceCodeStoreGCDisabled(), WithDeviceCodeStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomTenant, store.tenantID)
+}
+
+func TestWithDeviceCodeStoreLogger(t *testing.T) {
+	l := new(memoryLogger)
+
+	store, err := NewDeviceCodeStore(nil, WithDeviceCodeStoreLogger(l), WithDeviceCodeStoreGCDisabled(), WithDeviceCodeStoreInitTableDisabled())
+	require.NoError(t, err)
+
+	store.logger.Printf("log1", 1, "2", "333")
+
+	require.Equal(t, 1, len(l.formats))
+	assert.Equal(t, "log1", l.formats[0])
+}
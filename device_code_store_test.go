@@ -0,0 +1,217 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+	"github.com/vgarvardt/go-pg-adapter/sqladapter"
+)
+
+func TestDeviceCodeStore_initTable(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		query := args.Get(1).(string)
+		// new line character is the character at position 0
+		assert.Equal(t, 1, strings.Index(query, "CREATE TABLE IF NOT EXISTS"))
+	})
+
+	store, err := NewDeviceCodeStore(adapter, WithDeviceCodeStoreGCDisabled())
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	adapter.AssertExpectations(t)
+}
+
+func TestDeviceCodeStore_gc(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	var execCalls int
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		execCalls++
+
+		query := args.Get(1).(string)
+		assert.Equal(t, 0, strings.Index(query, "DELETE FROM"))
+	})
+
+	store, err := NewDeviceCodeStore(adapter, WithDeviceCodeStoreInitTableDisabled(), WithDeviceCodeStoreGCInterval(time.Second))
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	time.Sleep(3 * time.Second)
+
+	// in 3 seconds we should have 2-3 gc calls
+	assert.True(t, 1 < execCalls)
+	assert.True(t, 3 >= execCalls)
+}
+
+func TestDeviceCodeStore(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	conn, err := sql.Open("pgx", uri)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, conn.Close())
+	}()
+
+	for name, adapter := range map[string]pgAdapter.Adapter{
+		"pgx": pgx4adapter.NewPool(pool),
+		"sql": sqladapter.New(conn),
+	} {
+		t.Run(name, func(t *testing.T) {
+			store, err := NewDeviceCodeStore(
+				adapter,
+				WithDeviceCodeStoreTableName(fmt.Sprintf("device_code_%d", time.Now().UnixNano())),
+				WithDeviceCodeStoreGCDisabled(),
+			)
+			require.NoError(t, err)
+
+			runDeviceCodeStoreTest(t, store)
+		})
+	}
+}
+
+func runDeviceCodeStoreTest(t *testing.T, store *DeviceCodeStore) {
+	ctx := context.Background()
+	suffix := time.Now().String()
+
+	approved := &DeviceCode{
+		ExpiresAt:  time.Now().Add(time.Minute),
+		DeviceCode: "device approved " + suffix,
+		UserCode:   "user approved " + suffix,
+		ClientID:   "client",
+		Scope:      "read write",
+	}
+	require.NoError(t, store.Create(ctx, approved))
+	assert.Equal(t, DefaultDeviceCodeInterval, approved.Interval)
+
+	dc, err := store.GetByUserCode(ctx, approved.UserCode)
+	require.NoError(t, err)
+	assert.Equal(t, approved.DeviceCode, dc.DeviceCode)
+	assert.Equal(t, DeviceCodeStatusPending, dc.Status)
+	assert.Nil(t, dc.LastPolledAt)
+
+	_, err = store.Poll(ctx, approved.DeviceCode)
+	assert.Equal(t, ErrAuthorizationPending, err)
+
+	_, err = store.Poll(ctx, approved.DeviceCode)
+	assert.Equal(t, ErrSlowDown, err)
+
+	dc, err = store.GetByDeviceCode(ctx, approved.DeviceCode)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDeviceCodeInterval+slowDownIncrement, dc.Interval)
+	assert.NotNil(t, dc.LastPolledAt)
+
+	require.NoError(t, store.Approve(ctx, approved.UserCode, "user"))
+	assert.Equal(t, pgAdapter.ErrNoRows, store.Deny(ctx, approved.UserCode))
+
+	dc, err = store.Poll(ctx, approved.DeviceCode)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceCodeStatusApproved, dc.Status)
+	assert.Equal(t, "user", dc.UserID)
+	assert.Equal(t, "read write", dc.Scope)
+
+	// approved request is consumed by the poll
+	_, err = store.Poll(ctx, approved.DeviceCode)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+	require.NoError(t, store.RemoveByDeviceCode(ctx, approved.DeviceCode))
+
+	// concurrent polls of the approved request - only one gets it
+	concurrent := &DeviceCode{
+		ExpiresAt:  time.Now().Add(time.Minute),
+		DeviceCode: "device concurrent " + suffix,
+		UserCode:   "user concurrent " + suffix,
+	}
+	require.NoError(t, store.Create(ctx, concurrent))
+	require.NoError(t, store.Approve(ctx, concurrent.UserCode, "user"))
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Poll(ctx, concurrent.DeviceCode)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var succeeded int
+	for err := range results {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.Equal(t, pgAdapter.ErrNoRows, err)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	// requests of another tenant are not visible
+	tenantCtx := WithTenant(ctx, "tenant "+suffix)
+	tenant := &DeviceCode{
+		ExpiresAt:  time.Now().Add(time.Minute),
+		DeviceCode: "device tenant " + suffix,
+		UserCode:   "user tenant " + suffix,
+	}
+	require.NoError(t, store.Create(tenantCtx, tenant))
+	assert.Equal(t, "tenant "+suffix, tenant.TenantID)
+
+	_, err = store.GetByUserCode(ctx, tenant.UserCode)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+	assert.Equal(t, pgAdapter.ErrNoRows, store.Approve(ctx, tenant.UserCode, "user"))
+	_, err = store.Poll(ctx, tenant.DeviceCode)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	require.NoError(t, store.Approve(tenantCtx, tenant.UserCode, "user"))
+	dc, err = store.Poll(tenantCtx, tenant.DeviceCode)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant "+suffix, dc.TenantID)
+
+	denied := &DeviceCode{
+		ExpiresAt:  time.Now().Add(time.Minute),
+		DeviceCode: "device denied " + suffix,
+		UserCode:   "user denied " + suffix,
+	}
+	require.NoError(t, store.Create(ctx, denied))
+	require.NoError(t, store.Deny(ctx, denied.UserCode))
+
+	_, err = store.Poll(ctx, denied.DeviceCode)
+	assert.Equal(t, ErrAccessDenied, err)
+
+	expired := &DeviceCode{
+		ExpiresAt:  time.Now().Add(-time.Second),
+		DeviceCode: "device expired " + suffix,
+		UserCode:   "user expired " + suffix,
+	}
+	require.NoError(t, store.Create(ctx, expired))
+	assert.Equal(t, pgAdapter.ErrNoRows, store.Approve(ctx, expired.UserCode, "user"))
+
+	_, err = store.Poll(ctx, expired.DeviceCode)
+	assert.Equal(t, ErrExpiredToken, err)
+
+	store.clean()
+	_, err = store.GetByDeviceCode(ctx, expired.DeviceCode)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
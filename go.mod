@@ -8,6 +8,7 @@ require (
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/stretchr/testify v1.11.1
 	github.com/vgarvardt/go-pg-adapter v1.1.0
+	github.com/vgarvardt/pgx-helpers/v4 v4.2.0
 )
 
 require (
@@ -22,7 +23,6 @@ require (
 	github.com/jackc/puddle v1.3.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	github.com/vgarvardt/pgx-helpers/v4 v4.2.0 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
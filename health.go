@@ -0,0 +1,295 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// Health check statuses
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// Health is the store health check result
+type Health struct {
+	// Name is the checked store name
+	Name string `json:"name"`
+	// Status is HealthStatusOK if all the checks passed, HealthStatusFail otherwise
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the single health check result, error is empty for passed checks
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// OK checks if all the store health checks passed
+func (h *Health) OK() bool {
+	return h.Status == HealthStatusOK
+}
+
+func (h *Health) add(name string, err error) {
+	check := HealthCheck{Name: name, Status: HealthStatusOK}
+	if err != nil {
+		check.Status, check.Error = HealthStatusFail, err.Error()
+		h.Status = HealthStatusFail
+	}
+	h.Checks = append(h.Checks, check)
+}
+
+// HealthChecker is the store that is able to check its health
+type HealthChecker interface {
+	Health(ctx context.Context) *Health
+}
+
+// NewHealthHandler returns HTTP handler that checks health of the stores and reports it as JSON,
+// response status is 200 if all the stores are healthy and 503 otherwise, e.g. for Kubernetes readiness probe
+func NewHealthHandler(checkers ...HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := struct {
+			Status string    `json:"status"`
+			Stores []*Health `json:"stores"`
+		}{Status: HealthStatusOK, Stores: make([]*Health, 0, len(checkers))}
+
+		for _, c := range checkers {
+			health := c.Health(r.Context())
+			if !health.OK() {
+				report.Status = HealthStatusFail
+			}
+			report.Stores = append(report.Stores, health)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != HealthStatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// ping checks database connectivity
+func ping(ctx context.Context, adapter pgAdapter.Adapter) error {
+	var item struct {
+		One int `db:"one"`
+	}
+	return adapter.SelectOne(ctx, &item, "SELECT 1 AS one")
+}
+
+// checkSchema checks that the table exists, has all the columns the store works with and its schema version
+// is not older than the store one. Version is not checked for the tables created without the version comment,
+// e.g. by the migration tools, the columns check covers them.
+func checkSchema(ctx context.Context, adapter pgAdapter.Adapter, tableName string, schema tableSchema) error {
+	buf, err := json.Marshal(schema.columns)
+	if err != nil {
+		return err
+	}
+
+	var item struct {
+		Found   bool   `db:"found"`
+		Version int    `db:"version"`
+		Missing []byte `db:"missing"`
+	}
+	if err := adapter.SelectOne(ctx, &item, `
+SELECT
+	to_regclass($1) IS NOT NULL AS found,
+	COALESCE(substring(obj_description(to_regclass($1), 'pg_class') from $3)::int, 0) AS version,
+	COALESCE((
+		SELECT json_agg(c.name) FROM json_array_elements_text($2::json) AS c(name)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM pg_attribute a
+			WHERE a.attrelid = to_regclass($1) AND a.attname = c.name AND a.attnum > 0 AND NOT a.attisdropped
+		)
+	), '[]') AS missing`, tableName, string(buf), schemaVersionPattern); err != nil {
+		return err
+	}
+
+	var missing []string
+	if err := json.Unmarshal(item.Missing, &missing); err != nil {
+		return err
+	}
+
+	switch {
+	case !item.Found:
+		return fmt.Errorf("table %s does not exist", tableName)
+	case len(missing) > 0:
+		return fmt.Errorf("table %s misses columns: %s", tableName, strings.Join(missing, ", "))
+	case item.Version > 0 && item.Version < schema.version:
+		return fmt.Errorf("table %s schema version %d is older than expected %d", tableName, item.Version, schema.version)
+	}
+	return nil
+}
+
+// gcStatus keeps the result of the last garbage collection run
+type gcStatus struct {
+	interval time.Duration
+
+	mu            sync.Mutex
+	lastSuccessAt time.Time
+	lastErr       error
+}
+
+func newGCStatus(interval time.Duration) *gcStatus {
+	return &gcStatus{interval: interval, lastSuccessAt: time.Now()}
+}
+
+func (g *gcStatus) record(err error) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastErr = err
+	if err == nil {
+		g.lastSuccessAt = time.Now()
+	}
+}
+
+// check fails if garbage collection did not succeed for more than one interval, i.e. at least one run
+// in a row failed or was missed. Nil status means garbage collection is disabled and the check always passes.
+func (g *gcStatus) check() error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if since := time.Since(g.lastSuccessAt); since > 2*g.interval {
+		if g.lastErr != nil {
+			return fmt.Errorf("garbage collection did not succeed for %s: %w", since.Round(time.Second), g.lastErr)
+		}
+		return fmt.Errorf("garbage collection did not run for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// schemaVersionComment is the format of the table comment stores keep table schema version in
+const schemaVersionComment = "oauth2_pg schema version %d"
+
+// schemaVersionPattern is the regular expression extracting schema version from the table comment
+const schemaVersionPattern = "oauth2_pg schema version ([0-9]+)"
+
+// tableSchema is the table schema the store works with, version is increased on every table schema change
+type tableSchema struct {
+	version int
+	columns []string
+}
+
+// comment returns the table comment keeping schema version
+func (t tableSchema) comment() string {
+	return fmt.Sprintf(schemaVersionComment, t.version)
+}
+
+// tokenTableSchema is the token table schema the store works with
+var tokenTableSchema = tableSchema{version: 2, columns: []string{
+	"id", "created_at", "expires_at", "code", "access", "refresh", "data", "tenant_id", "client_id", "user_id",
+	"last_used_at", "use_count", "idle_expires_at", "code_expires_at", "access_expires_at", "refresh_expires_at",
+	"code_challenge", "code_challenge_method", "nonce", "redirect_uri",
+}}
+
+// clientTableSchema is the client table schema the store works with
+var clientTableSchema = tableSchema{version: 2, columns: []string{
+	"id", "secret", "domain", "data", "tenant_id", "public", "user_id", "redirect_uris", "grant_types", "scopes",
+	"access_token_lifetime", "refresh_token_lifetime", "disabled", "disabled_reason", "expires_at",
+}}
+
+// clientSecretsTableSchema is the client secrets table schema the store works with
+var clientSecretsTableSchema = tableSchema{version: 2, columns: []string{
+	"id", "client_id", "secret_hash", "label", "created_at", "expires_at", "tenant_id",
+}}
+
+// pushedRequestTableSchema is the pushed request table schema the store works with
+var pushedRequestTableSchema = tableSchema{version: 1, columns: []string{
+	"id", "created_at", "expires_at", "request_uri", "client_id", "params", "tenant_id",
+}}
+
+// auditTableSchema is the audit table schema the stores work with
+var auditTableSchema = tableSchema{version: 1, columns: []string{
+	"id", "created_at", "event", "tenant_id", "client_id", "user_id", "token_id", "token_hash", "scope", "actor",
+	"reason", "metadata", "seq", "prev_hash", "hash",
+}}
+
+// Ping checks token store database connectivity
+func (s *TokenStore) Ping(ctx context.Context) error {
+	ctx, done := s.limits.start(ctx, opRead, "token store ping")
+	defer done()
+
+	return ping(ctx, s.db(ctx))
+}
+
+// Health checks token store database connectivity, tables schema and version and garbage collection runs
+func (s *TokenStore) Health(ctx context.Context) *Health {
+	ctx, done := s.limits.start(ctx, opRead, "token store health check")
+	defer done()
+
+	health := &Health{Name: "token_store", Status: HealthStatusOK}
+	health.add("ping", ping(ctx, s.db(ctx)))
+	health.add("schema", checkSchema(ctx, s.db(ctx), s.tableName, tokenTableSchema))
+	if s.audit.enabled {
+		health.add("audit_schema", checkSchema(ctx, s.db(ctx), s.audit.tableName, auditTableSchema))
+	}
+	health.add("gc", s.gcStatus.check())
+
+	return health
+}
+
+// Ping checks client store database connectivity
+func (s *ClientStore) Ping(ctx context.Context) error {
+	ctx, done := s.limits.start(ctx, opRead, "client store ping")
+	defer done()
+
+	return ping(ctx, s.db(ctx))
+}
+
+// Health checks client store database connectivity, tables schema and version and garbage collection runs
+func (s *ClientStore) Health(ctx context.Context) *Health {
+	ctx, done := s.limits.start(ctx, opRead, "client store health check")
+	defer done()
+
+	health := &Health{Name: "client_store", Status: HealthStatusOK}
+	health.add("ping", ping(ctx, s.db(ctx)))
+	health.add("schema", checkSchema(ctx, s.db(ctx), s.tableName, clientTableSchema))
+	if s.secretRotation {
+		health.add("secrets_schema", checkSchema(ctx, s.db(ctx), s.secretsTableName, clientSecretsTableSchema))
+	}
+	if s.audit.enabled {
+		health.add("audit_schema", checkSchema(ctx, s.db(ctx), s.audit.tableName, auditTableSchema))
+	}
+	health.add("gc", s.gcStatus.check())
+
+	return health
+}
+
+// Ping checks pushed request store database connectivity
+func (s *PushedRequestStore) Ping(ctx context.Context) error {
+	ctx, done := s.limits.start(ctx, opRead, "pushed request store ping")
+	defer done()
+
+	return ping(ctx, s.db(ctx))
+}
+
+// Health checks pushed request store database connectivity, table schema and version and garbage collection runs
+func (s *PushedRequestStore) Health(ctx context.Context) *Health {
+	ctx, done := s.limits.start(ctx, opRead, "pushed request store health check")
+	defer done()
+
+	health := &Health{Name: "pushed_request_store", Status: HealthStatusOK}
+	health.add("ping", ping(ctx, s.db(ctx)))
+	health.add("schema", checkSchema(ctx, s.db(ctx), s.tableName, pushedRequestTableSchema))
+	health.add("gc", s.gcStatus.check())
+
+	return health
+}
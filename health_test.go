@@ -0,0 +1,145 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+type staticHealthChecker struct {
+	health *Health
+}
+
+func (c staticHealthChecker) Health(context.Context) *Health {
+	return c.health
+}
+
+func TestNewHealthHandler(t *testing.T) {
+	healthy := &Health{Name: "token_store", Status: HealthStatusOK}
+	healthy.add("ping", nil)
+	unhealthy := &Health{Name: "client_store", Status: HealthStatusOK}
+	unhealthy.add("ping", errors.New("connection refused"))
+
+	var report struct {
+		Status string    `json:"status"`
+		Stores []*Health `json:"stores"`
+	}
+
+	w := httptest.NewRecorder()
+	NewHealthHandler(staticHealthChecker{healthy}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, HealthStatusOK, report.Status)
+	assert.Equal(t, []*Health{healthy}, report.Stores)
+
+	w = httptest.NewRecorder()
+	NewHealthHandler(staticHealthChecker{healthy}, staticHealthChecker{unhealthy}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, HealthStatusFail, report.Status)
+	require.Len(t, report.Stores, 2)
+	assert.Equal(t, HealthCheck{Name: "ping", Status: HealthStatusFail, Error: "connection refused"}, report.Stores[1].Checks[0])
+}
+
+func TestGCStatus_check(t *testing.T) {
+	var disabled *gcStatus
+	disabled.record(errors.New("ignored"))
+	assert.NoError(t, disabled.check())
+
+	status := newGCStatus(time.Minute)
+	assert.NoError(t, status.check())
+
+	// single failed run is tolerated
+	status.record(errors.New("statement timeout"))
+	assert.NoError(t, status.check())
+
+	status.lastSuccessAt = time.Now().Add(-3 * time.Minute)
+	assert.EqualError(t, status.check(), "garbage collection did not succeed for 3m0s: statement timeout")
+
+	status.record(nil)
+	assert.NoError(t, status.check())
+
+	status.lastSuccessAt = time.Now().Add(-3 * time.Minute)
+	assert.EqualError(t, status.check(), "garbage collection did not run for 3m0s")
+}
+
+func TestStores_Health(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	ctx := context.Background()
+
+	tokenStore, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreAudit())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	clientStore, err := NewClientStore(adapter, WithClientStoreTableName(generateClientTableName()), WithClientStoreSecretRotation())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, clientStore.Close())
+	}()
+
+	require.NoError(t, tokenStore.Ping(ctx))
+	require.NoError(t, clientStore.Ping(ctx))
+
+	health := tokenStore.Health(ctx)
+	assert.True(t, health.OK(), "%+v", health)
+	assert.Len(t, health.Checks, 4)
+
+	health = clientStore.Health(ctx)
+	assert.True(t, health.OK(), "%+v", health)
+	assert.Len(t, health.Checks, 4)
+
+	requestStore, err := NewPushedRequestStore(adapter, WithPushedRequestStoreTableName(fmt.Sprintf("pushed_request_health_%d", time.Now().UnixNano())))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, requestStore.Close())
+	}()
+
+	require.NoError(t, requestStore.Ping(ctx))
+	health = requestStore.Health(ctx)
+	assert.True(t, health.OK(), "%+v", health)
+	assert.Len(t, health.Checks, 3)
+
+	// store with the table not created reports it
+	missingStore, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	health = missingStore.Health(ctx)
+	assert.False(t, health.OK())
+	assert.Equal(t, HealthCheck{Name: "schema", Status: HealthStatusFail, Error: "table " + missingStore.tableName + " does not exist"}, health.Checks[1])
+
+	// store with the table of older schema version reports it
+	newerSchema := tableSchema{version: clientTableSchema.version + 1, columns: clientTableSchema.columns}
+	assert.EqualError(
+		t,
+		checkSchema(ctx, adapter, clientStore.tableName, newerSchema),
+		fmt.Sprintf("table %s schema version %d is older than expected %d", clientStore.tableName, clientTableSchema.version, newerSchema.version),
+	)
+
+	// table without the version comment is checked for the columns only
+	require.NoError(t, adapter.Exec(ctx, "COMMENT ON TABLE "+clientStore.tableName+" IS NULL"))
+	assert.NoError(t, checkSchema(ctx, adapter, clientStore.tableName, newerSchema))
+
+	// store with outdated table schema reports missing columns
+	require.NoError(t, adapter.Exec(ctx, "ALTER TABLE "+tokenStore.tableName+" DROP COLUMN idle_expires_at"))
+	health = tokenStore.Health(ctx)
+	assert.False(t, health.OK())
+	assert.Equal(t, HealthCheck{Name: "schema", Status: HealthStatusFail, Error: "table " + tokenStore.tableName + " misses columns: idle_expires_at"}, health.Checks[1])
+}
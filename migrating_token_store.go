@@ -0,0 +1,125 @@
+package pg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-oauth2/oauth2/v4"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// MigratingTokenStore is the token store that moves tokens from the legacy store, e.g. in-memory, BuntDB or Redis
+// one, to the PostgreSQL token store without invalidating them. New tokens are written to both stores, lookups
+// go to PostgreSQL first and fall back to the legacy store, tokens found there are copied to PostgreSQL once,
+// even by the concurrent lookups, if the token store adapter is Transactor. Removals are made in both stores,
+// so that switching back to the legacy store is possible.
+// Once the migration runs for the longest token lifetime, legacy store is not needed any more and the wrapper
+// can be replaced with the PostgreSQL token store itself.
+type MigratingTokenStore struct {
+	legacy oauth2.TokenStore
+	store  *TokenStore
+}
+
+// NewMigratingTokenStore creates token store that migrates tokens from the legacy store to the PostgreSQL one
+func NewMigratingTokenStore(legacy oauth2.TokenStore, store *TokenStore) *MigratingTokenStore {
+	return &MigratingTokenStore{legacy: legacy, store: store}
+}
+
+// Create creates and stores the new token information in both stores. Token written to PostgreSQL is removed
+// if the legacy store fails to write it, so that the stores do not diverge.
+func (s *MigratingTokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
+	if err := s.store.Create(ctx, info); err != nil {
+		return err
+	}
+
+	err := s.legacy.Create(ctx, info)
+	if err == nil {
+		return nil
+	}
+
+	var removeErr error
+	switch {
+	case info.GetCode() != "":
+		removeErr = s.store.RemoveByCode(ctx, info.GetCode())
+	case info.GetAccess() != "":
+		removeErr = s.store.RemoveByAccess(ctx, info.GetAccess())
+	case info.GetRefresh() != "":
+		removeErr = s.store.RemoveByRefresh(ctx, info.GetRefresh())
+	}
+	if removeErr != nil {
+		return errors.Join(err, removeErr)
+	}
+	return err
+}
+
+// RemoveByCode deletes the authorization code from both stores
+func (s *MigratingTokenStore) RemoveByCode(ctx context.Context, code string) error {
+	return errors.Join(s.store.RemoveByCode(ctx, code), s.legacy.RemoveByCode(ctx, code))
+}
+
+// RemoveByAccess deletes the access token from both stores
+func (s *MigratingTokenStore) RemoveByAccess(ctx context.Context, access string) error {
+	return errors.Join(s.store.RemoveByAccess(ctx, access), s.legacy.RemoveByAccess(ctx, access))
+}
+
+// RemoveByRefresh deletes the refresh token from both stores
+func (s *MigratingTokenStore) RemoveByRefresh(ctx context.Context, refresh string) error {
+	return errors.Join(s.store.RemoveByRefresh(ctx, refresh), s.legacy.RemoveByRefresh(ctx, refresh))
+}
+
+// GetByCode uses the authorization code for token information data
+func (s *MigratingTokenStore) GetByCode(ctx context.Context, code string) (oauth2.TokenInfo, error) {
+	return s.get(ctx, code, s.store.GetByCode, s.legacy.GetByCode)
+}
+
+// GetByAccess uses the access token for token information data
+func (s *MigratingTokenStore) GetByAccess(ctx context.Context, access string) (oauth2.TokenInfo, error) {
+	return s.get(ctx, access, s.store.GetByAccess, s.legacy.GetByAccess)
+}
+
+// GetByRefresh uses the refresh token for token information data
+func (s *MigratingTokenStore) GetByRefresh(ctx context.Context, refresh string) (oauth2.TokenInfo, error) {
+	return s.get(ctx, refresh, s.store.GetByRefresh, s.legacy.GetByRefresh)
+}
+
+type tokenLookup func(ctx context.Context, value string) (oauth2.TokenInfo, error)
+
+// get looks the token up in PostgreSQL and falls back to the legacy store if it is not there.
+// Failed copying of the legacy token is logged only, as the token is still valid in the legacy store.
+func (s *MigratingTokenStore) get(ctx context.Context, value string, lookup, legacyLookup tokenLookup) (oauth2.TokenInfo, error) {
+	info, err := lookup(ctx, value)
+	if err != pgAdapter.ErrNoRows {
+		return info, err
+	}
+
+	legacyInfo, legacyErr := legacyLookup(ctx, value)
+	if legacyErr != nil {
+		return nil, legacyErr
+	}
+	if legacyInfo == nil {
+		return info, err
+	}
+
+	if err := s.copy(ctx, value, lookup, legacyInfo); err != nil {
+		s.store.logger.Printf("Error while copying token from legacy store: %+v", err)
+	}
+
+	return legacyInfo, nil
+}
+
+// copy writes the legacy token to PostgreSQL unless the concurrent lookup has already copied it. Copies of the same
+// token are serialized with the transaction-level advisory lock, so the lock holds only if the store adapter
+// is Transactor or the transaction is set to the context.
+func (s *MigratingTokenStore) copy(ctx context.Context, value string, lookup tokenLookup, info oauth2.TokenInfo) error {
+	return runInTx(ctx, s.store.db(ctx), func(ctx context.Context, tx pgAdapter.Adapter) error {
+		if err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.store.tableName+" "+value); err != nil {
+			return err
+		}
+
+		if _, err := lookup(ctx, value); err != pgAdapter.ErrNoRows {
+			return err
+		}
+		return s.store.Create(ctx, info)
+	})
+}
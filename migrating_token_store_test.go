@@ -0,0 +1,217 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// memoryTokenStore is the legacy token store keeping tokens by access token only
+type memoryTokenStore struct {
+	tokens map[string]oauth2.TokenInfo
+	err    error
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{tokens: make(map[string]oauth2.TokenInfo)}
+}
+
+func (s *memoryTokenStore) Create(_ context.Context, info oauth2.TokenInfo) error {
+	s.tokens[info.GetAccess()] = info
+	return s.err
+}
+
+func (s *memoryTokenStore) RemoveByCode(context.Context, string) error {
+	return s.err
+}
+
+func (s *memoryTokenStore) RemoveByAccess(_ context.Context, access string) error {
+	delete(s.tokens, access)
+	return s.err
+}
+
+func (s *memoryTokenStore) RemoveByRefresh(context.Context, string) error {
+	return s.err
+}
+
+func (s *memoryTokenStore) GetByCode(context.Context, string) (oauth2.TokenInfo, error) {
+	return nil, s.err
+}
+
+func (s *memoryTokenStore) GetByAccess(_ context.Context, access string) (oauth2.TokenInfo, error) {
+	return s.tokens[access], s.err
+}
+
+func (s *memoryTokenStore) GetByRefresh(context.Context, string) (oauth2.TokenInfo, error) {
+	return nil, s.err
+}
+
+func TestMigratingTokenStore_GetByAccess(t *testing.T) {
+	ctx := context.Background()
+	adapter := new(mockAdapter)
+	legacy := newMemoryTokenStore()
+
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled(), WithTokenStoreLogger(new(memoryLogger)))
+	require.NoError(t, err)
+	migrating := NewMigratingTokenStore(legacy, store)
+
+	token := models.NewToken()
+	token.SetAccess("legacy access")
+	token.SetAccessCreateAt(time.Now())
+	token.SetAccessExpiresIn(time.Hour)
+	require.NoError(t, legacy.Create(ctx, token))
+
+	// token found in the legacy store only is copied
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows)
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+	info, err := migrating.GetByAccess(ctx, "legacy access")
+	require.NoError(t, err)
+	assert.Equal(t, token, info)
+	adapter.AssertNumberOfCalls(t, "Exec", 2)
+	// token is looked up once again under the copy lock
+	assert.Contains(t, adapter.Calls[1].Arguments.Get(1), "pg_advisory_xact_lock")
+	assert.Equal(t, "SelectOne", adapter.Calls[2].Method)
+	assert.Equal(t, "legacy access", adapter.Calls[3].Arguments.Get(2).([]interface{})[4])
+
+	// token found in neither store results in PostgreSQL store error
+	_, err = migrating.GetByAccess(ctx, "unknown access")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+	adapter.AssertNumberOfCalls(t, "Exec", 2)
+
+	// legacy store errors are returned
+	legacy.err = errors.New("legacy store is down")
+	_, err = migrating.GetByAccess(ctx, "legacy access")
+	assert.Equal(t, legacy.err, err)
+}
+
+func TestMigratingTokenStore_CreateLegacyFailure(t *testing.T) {
+	ctx := context.Background()
+	adapter := new(mockAdapter)
+	legacy := newMemoryTokenStore()
+
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	migrating := NewMigratingTokenStore(legacy, store)
+
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	// token written to PostgreSQL is removed by access when the legacy store fails
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.MatchedBy(func(query string) bool {
+		return strings.Contains(query, "DELETE") && strings.Contains(query, "access = $1")
+	}), []interface{}{"access", ""}).Return(nil).Run(func(args mock.Arguments) {
+		reflect.ValueOf(args.Get(1)).Elem().Field(0).SetString(`["access"]`)
+	}).Once()
+
+	token := models.NewToken()
+	token.SetAccess("access")
+	token.SetAccessCreateAt(time.Now())
+	token.SetAccessExpiresIn(time.Hour)
+
+	legacy.err = errors.New("legacy store is down")
+	assert.Equal(t, legacy.err, migrating.Create(ctx, token))
+	adapter.AssertExpectations(t)
+}
+
+func TestMigratingTokenStore_Remove(t *testing.T) {
+	ctx := context.Background()
+	adapter := new(mockAdapter)
+	legacy := newMemoryTokenStore()
+
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	migrating := NewMigratingTokenStore(legacy, store)
+
+	pgErr := errors.New("pg is down")
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgErr)
+
+	legacy.tokens["access"] = models.NewToken()
+	err = migrating.RemoveByAccess(ctx, "access")
+	assert.ErrorIs(t, err, pgErr)
+	// removal is made in the legacy store regardless of PostgreSQL store failure
+	assert.Empty(t, legacy.tokens)
+
+	legacy.err = errors.New("legacy store is down")
+	err = migrating.RemoveByRefresh(ctx, "refresh")
+	assert.ErrorIs(t, err, pgErr)
+	assert.ErrorIs(t, err, legacy.err)
+}
+
+func TestMigratingTokenStore(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ctx := context.Background()
+	legacy := newMemoryTokenStore()
+
+	store, err := NewTokenStore(NewPGXPool(pool), WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+	migrating := NewMigratingTokenStore(legacy, store)
+
+	newToken := func() *models.Token {
+		token := models.NewToken()
+		token.SetAccess(fmt.Sprintf("access %s", time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Hour)
+		return token
+	}
+
+	// new tokens are written to both stores
+	created := newToken()
+	require.NoError(t, migrating.Create(ctx, created))
+	assert.Contains(t, legacy.tokens, created.GetAccess())
+	_, err = store.GetByAccess(ctx, created.GetAccess())
+	require.NoError(t, err)
+
+	// legacy tokens are copied on lookup
+	legacyToken := newToken()
+	require.NoError(t, legacy.Create(ctx, legacyToken))
+	info, err := migrating.GetByAccess(ctx, legacyToken.GetAccess())
+	require.NoError(t, err)
+	assert.Equal(t, legacyToken.GetAccess(), info.GetAccess())
+	info, err = store.GetByAccess(ctx, legacyToken.GetAccess())
+	require.NoError(t, err)
+	assert.Equal(t, legacyToken.GetAccess(), info.GetAccess())
+
+	// concurrent lookups copy the legacy token once
+	concurrent := newToken()
+	require.NoError(t, legacy.Create(ctx, concurrent))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := migrating.GetByAccess(ctx, concurrent.GetAccess())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	info, err = store.GetByAccess(ctx, concurrent.GetAccess())
+	require.NoError(t, err)
+	assert.Equal(t, concurrent.GetAccess(), info.GetAccess())
+
+	// removals are made in both stores
+	require.NoError(t, migrating.RemoveByAccess(ctx, legacyToken.GetAccess()))
+	assert.NotContains(t, legacy.tokens, legacyToken.GetAccess())
+	_, err = migrating.GetByAccess(ctx, legacyToken.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"time"
+)
+
+// OperationTimeouts are the default timeouts of the store operation classes, applied only when the operation
+// context has no deadline. Zero timeout leaves the operation not limited.
+type OperationTimeouts struct {
+	// Read is the timeout of the lookups
+	Read time.Duration
+	// Write is the timeout of the creations, updates and removals, including token usage flushing
+	Write time.Duration
+	// GC is the timeout of the background garbage collection
+	GC time.Duration
+	// Migration is the timeout of the table creation on store instantiation
+	Migration time.Duration
+}
+
+type operationClass int
+
+const (
+	opRead operationClass = iota
+	opWrite
+	opGC
+	opMigration
+)
+
+func (c operationClass) String() string {
+	switch c {
+	case opRead:
+		return "read"
+	case opWrite:
+		return "write"
+	case opGC:
+		return "gc"
+	default:
+		return "migration"
+	}
+}
+
+// operationLimits applies default timeouts to the store operations and reports the slow ones
+type operationLimits struct {
+	timeouts      OperationTimeouts
+	slowThreshold time.Duration
+	logger        Logger
+}
+
+func (l operationLimits) timeout(class operationClass) time.Duration {
+	switch class {
+	case opRead:
+		return l.timeouts.Read
+	case opWrite:
+		return l.timeouts.Write
+	case opGC:
+		return l.timeouts.GC
+	default:
+		return l.timeouts.Migration
+	}
+}
+
+// start returns the operation context with the class timeout applied if the context has no deadline,
+// returned function must be called when the operation completes
+func (l operationLimits) start(ctx context.Context, class operationClass, operation string) (context.Context, func()) {
+	cancel := func() {}
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := l.timeout(class); timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+	}
+
+	startedAt := time.Now()
+	return ctx, func() {
+		cancel()
+		if elapsed := time.Since(startedAt); l.slowThreshold > 0 && elapsed >= l.slowThreshold {
+			l.logger.Printf("Slow %s operation %s took %s", class, operation, elapsed)
+		}
+	}
+}
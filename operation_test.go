@@ -0,0 +1,93 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationLimits_start(t *testing.T) {
+	l := operationLimits{
+		timeouts: OperationTimeouts{Read: time.Second, Write: 2 * time.Second, GC: 3 * time.Second, Migration: 4 * time.Second},
+		logger:   new(memoryLogger),
+	}
+
+	for class, timeout := range map[operationClass]time.Duration{
+		opRead:      time.Second,
+		opWrite:     2 * time.Second,
+		opGC:        3 * time.Second,
+		opMigration: 4 * time.Second,
+	} {
+		ctx, done := l.start(context.Background(), class, "test")
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, class.String())
+		assert.WithinDuration(t, time.Now().Add(timeout), deadline, 100*time.Millisecond, class.String())
+
+		done()
+		assert.Equal(t, context.Canceled, ctx.Err(), class.String())
+	}
+
+	// context deadline takes precedence over the default timeout
+	parent, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	ctx, done := l.start(parent, opRead, "test")
+	deadline, _ := ctx.Deadline()
+	parentDeadline, _ := parent.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
+	done()
+	assert.NoError(t, parent.Err())
+
+	// zero timeout leaves the operation not limited
+	ctx, done = operationLimits{}.start(context.Background(), opWrite, "test")
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	done()
+}
+
+func TestOperationLimits_slowQuery(t *testing.T) {
+	logger := new(memoryLogger)
+	l := operationLimits{slowThreshold: 10 * time.Millisecond, logger: logger}
+
+	_, done := l.start(context.Background(), opRead, "fast")
+	done()
+	assert.Len(t, logger.formats, 0)
+
+	_, done = l.start(context.Background(), opWrite, "slow")
+	time.Sleep(15 * time.Millisecond)
+	done()
+	require.Len(t, logger.formats, 1)
+	assert.Equal(t, opWrite, logger.args[0][0])
+	assert.Equal(t, "slow", logger.args[0][1])
+
+	// slow query logging is disabled by default
+	_, done = operationLimits{logger: logger}.start(context.Background(), opRead, "not reported")
+	time.Sleep(15 * time.Millisecond)
+	done()
+	assert.Len(t, logger.formats, 1)
+}
+
+func TestTokenStore_GetByAccess_timeout(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(context.DeadlineExceeded).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreTimeouts(OperationTimeouts{Read: time.Minute}),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	_, err = store.GetByAccess(context.Background(), "access")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	adapter.AssertExpectations(t)
+}
@@ -0,0 +1,245 @@
+// Package par implements OAuth 2.0 Pushed Authorization Requests (RFC 9126) endpoint backed by pg.PushedRequestStore,
+// clients are authenticated with pg.ClientStore.
+package par
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+
+	pg "github.com/vgarvardt/go-oauth2-pg/v4"
+)
+
+// maxBodySize is the maximum accepted pushed authorization request body size
+const maxBodySize = 1 << 20
+
+// ErrInvalidRequestURI is returned by Handler.Resolve for unknown, expired or already used request URI,
+// and for the request URI of another client
+var ErrInvalidRequestURI = errors.New("invalid_request_uri")
+
+// Handler serves pushed authorization request endpoint
+type Handler struct {
+	requests *pg.PushedRequestStore
+	clients  *pg.ClientStore
+	logger   pg.Logger
+
+	expiresIn time.Duration
+}
+
+type requestError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+type pushedResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// NewHandler creates pushed authorization request handler instance
+func NewHandler(requests *pg.PushedRequestStore, clients *pg.ClientStore, options ...Option) *Handler {
+	h := &Handler{
+		requests:  requests,
+		clients:   clients,
+		logger:    log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		expiresIn: pg.DefaultPushedRequestLifetime,
+	}
+
+	for _, o := range options {
+		o(h)
+	}
+
+	return h
+}
+
+// ServeHTTP authenticates the client and stores its authorization request parameters, responds with the request URI
+// the client passes to the authorization endpoint instead of the parameters
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, &requestError{Code: "invalid_request", Description: "malformed request body"})
+		return
+	}
+
+	client, rErr, err := h.authenticate(r)
+	if err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+	if rErr != nil {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="par"`)
+		}
+		writeError(w, http.StatusUnauthorized, rErr)
+		return
+	}
+
+	if rErr := validate(client, r.PostForm); rErr != nil {
+		writeError(w, http.StatusBadRequest, rErr)
+		return
+	}
+
+	params := make(url.Values, len(r.PostForm))
+	for k, v := range r.PostForm {
+		if k != "client_secret" {
+			params[k] = v
+		}
+	}
+	params.Set("client_id", client.GetID())
+
+	now := time.Now()
+	pr := &pg.PushedRequest{CreatedAt: now, ExpiresAt: now.Add(h.expiresIn), ClientID: client.GetID(), Params: params}
+	if err := h.requests.Create(r.Context(), pr); err != nil {
+		h.writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, &pushedResponse{RequestURI: pr.RequestURI, ExpiresIn: int64(h.expiresIn / time.Second)})
+}
+
+// Resolve replaces authorization request parameters with the pushed ones when the request has request_uri
+// parameter, so that authorization endpoint handles it as usual, e.g. with oauth2 server HandleAuthorizeRequest.
+// Parameters other than the pushed ones are ignored, see RFC 9126 section 4. Pushed request is consumed,
+// so request URI can be used once only. Requests without request_uri are left as is.
+func (h *Handler) Resolve(r *http.Request) error {
+	requestURI := r.FormValue("request_uri")
+	if requestURI == "" {
+		return nil
+	}
+
+	pr, err := h.requests.Consume(r.Context(), requestURI, r.FormValue("client_id"))
+	if err == pgAdapter.ErrNoRows {
+		return ErrInvalidRequestURI
+	}
+	if err != nil {
+		return err
+	}
+
+	r.Form = pr.Params
+	r.PostForm = url.Values{}
+	return nil
+}
+
+// authenticate authenticates the client with client_secret_basic or client_secret_post method,
+// public clients are identified by client_id only
+func (h *Handler) authenticate(r *http.Request) (oauth2.ClientInfo, *requestError, error) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// basic credentials are form-urlencoded, see RFC 6749 section 2.3.1
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return nil, invalidClient(), nil
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, invalidClient(), nil
+		}
+		if id := r.PostForm.Get("client_id"); id != "" && id != clientID {
+			return nil, invalidClient(), nil
+		}
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID == "" {
+		return nil, invalidClient(), nil
+	}
+
+	client, err := h.clients.GetByID(r.Context(), clientID)
+	switch {
+	case err == pgAdapter.ErrNoRows || err == pg.ErrClientDisabled || (err == nil && client == nil):
+		return nil, invalidClient(), nil
+	case err != nil:
+		return nil, nil, err
+	}
+
+	if client.IsPublic() {
+		return client, nil, nil
+	}
+	if secret == "" || !verifySecret(client, secret) {
+		return nil, invalidClient(), nil
+	}
+	return client, nil, nil
+}
+
+func verifySecret(client oauth2.ClientInfo, secret string) bool {
+	if v, ok := client.(oauth2.ClientPasswordVerifier); ok {
+		return v.VerifyPassword(secret)
+	}
+	return subtle.ConstantTimeCompare([]byte(client.GetSecret()), []byte(secret)) == 1
+}
+
+// validate checks the pushed authorization request parameters, see RFC 9126 section 2.1
+func validate(client oauth2.ClientInfo, params url.Values) *requestError {
+	if params.Get("request_uri") != "" {
+		return &requestError{Code: "invalid_request", Description: "request_uri must not be pushed"}
+	}
+	if params.Get("response_type") == "" {
+		return &requestError{Code: "invalid_request", Description: "response_type is required"}
+	}
+
+	// redirect URI is checked early, so that the client gets the error right away
+	redirectURI := params.Get("redirect_uri")
+	info := pg.UnwrapClient(client)
+	m, ok := info.(pg.ClientMetadata)
+	if !ok || redirectURI == "" || len(m.GetRedirectURIs()) == 0 {
+		return nil
+	}
+
+	// client own validation may accept not exactly matching URIs, e.g. loopback ones with any port, see RFC 8252
+	if v, ok := info.(redirectURIValidator); ok {
+		if v.ValidateRedirectURI(redirectURI) != nil {
+			return unregisteredRedirectURI()
+		}
+		return nil
+	}
+	for _, u := range m.GetRedirectURIs() {
+		if u == redirectURI {
+			return nil
+		}
+	}
+	return unregisteredRedirectURI()
+}
+
+// redirectURIValidator is the client validating redirect URIs itself, e.g. pg.Client
+type redirectURIValidator interface {
+	ValidateRedirectURI(redirectURI string) error
+}
+
+func unregisteredRedirectURI() *requestError {
+	return &requestError{Code: "invalid_request", Description: "redirect_uri is not registered for the client"}
+}
+
+func invalidClient() *requestError {
+	return &requestError{Code: "invalid_client", Description: "client authentication failed"}
+}
+
+func (h *Handler) writeServerError(w http.ResponseWriter, err error) {
+	h.logger.Printf("Error while handling pushed authorization request: %+v", err)
+	writeError(w, http.StatusInternalServerError, &requestError{Code: "server_error", Description: "internal server error"})
+}
+
+func writeError(w http.ResponseWriter, status int, err *requestError) {
+	writeJSON(w, status, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
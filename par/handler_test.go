@@ -0,0 +1,240 @@
+package par
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+
+	pg "github.com/vgarvardt/go-oauth2-pg/v4"
+)
+
+var uri string
+
+func TestMain(m *testing.M) {
+	uri = os.Getenv("PG_URI")
+	if uri == "" {
+		fmt.Println("Env variable PG_URI is required to run the tests")
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestHandler_methodNotAllowed(t *testing.T) {
+	h := NewHandler(nil, nil)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/par", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+}
+
+func TestValidate(t *testing.T) {
+	client := &pg.Client{ID: "client", RedirectURIs: []string{"https://client.example.com/cb"}}
+
+	assert.Nil(t, validate(client, url.Values{"response_type": {"code"}, "redirect_uri": {"https://client.example.com/cb"}}))
+	assert.Nil(t, validate(&pg.Client{ID: "any"}, url.Values{"response_type": {"code"}, "redirect_uri": {"https://any.example.com/cb"}}))
+
+	// client loaded by the store with secret rotation enabled is checked the same way
+	rotating := &pg.RotatingSecretClient{ClientInfo: client}
+	assert.Nil(t, validate(rotating, url.Values{"response_type": {"code"}, "redirect_uri": {"https://client.example.com/cb"}}))
+	assert.NotNil(t, validate(rotating, url.Values{"response_type": {"code"}, "redirect_uri": {"https://evil.example.com/cb"}}))
+
+	// native app loopback redirect URI is accepted with any port
+	native := &pg.RotatingSecretClient{ClientInfo: &pg.Client{ID: "native", RedirectURIs: []string{"http://127.0.0.1/cb"}}}
+	assert.Nil(t, validate(native, url.Values{"response_type": {"code"}, "redirect_uri": {"http://127.0.0.1:51234/cb"}}))
+	assert.NotNil(t, validate(native, url.Values{"response_type": {"code"}, "redirect_uri": {"http://127.0.0.1:51234/other"}}))
+
+	for name, params := range map[string]url.Values{
+		"request uri":           {"response_type": {"code"}, "request_uri": {pg.PushedRequestURIPrefix + "foo"}},
+		"missing response type": {"redirect_uri": {"https://client.example.com/cb"}},
+		"unknown redirect uri":  {"response_type": {"code"}, "redirect_uri": {"https://evil.example.com/cb"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rErr := validate(client, params)
+			require.NotNil(t, rErr)
+			assert.Equal(t, "invalid_request", rErr.Code)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := pgx4adapter.NewPool(pool)
+	clients, err := pg.NewClientStore(
+		adapter,
+		pg.WithClientStoreTableName(fmt.Sprintf("client_par_%d", time.Now().UnixNano())),
+		pg.WithClientStoreCodec(pg.JSONCodec{}, pg.NewClientInfo),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, clients.Close())
+	}()
+
+	requests, err := pg.NewPushedRequestStore(
+		adapter,
+		pg.WithPushedRequestStoreTableName(fmt.Sprintf("pushed_request_par_%d", time.Now().UnixNano())),
+		pg.WithPushedRequestStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, requests.Close())
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, clients.Create(ctx, &pg.Client{ID: "confidential", Secret: "secret", RedirectURIs: []string{"https://client.example.com/cb"}}))
+	require.NoError(t, clients.Create(ctx, &pg.Client{ID: "public", Public: true, RedirectURIs: []string{"https://public.example.com/cb"}}))
+
+	h := NewHandler(requests, clients, WithExpiresIn(90*time.Second))
+
+	push := func(form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/par", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicID != "" {
+			r.SetBasicAuth(url.QueryEscape(basicID), url.QueryEscape(basicSecret))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	params := url.Values{"response_type": {"code"}, "redirect_uri": {"https://client.example.com/cb"}, "state": {"xyz"}}
+
+	for name, tc := range map[string]struct {
+		form                     url.Values
+		basicID, basicSecret     string
+		status                   int
+		code, authenticateHeader string
+	}{
+		"unknown client":    {params, "unknown", "secret", http.StatusUnauthorized, "invalid_client", `Basic realm="par"`},
+		"invalid secret":    {params, "confidential", "invalid", http.StatusUnauthorized, "invalid_client", `Basic realm="par"`},
+		"missing secret":    {url.Values{"client_id": {"confidential"}, "response_type": {"code"}}, "", "", http.StatusUnauthorized, "invalid_client", ""},
+		"another client id": {url.Values{"client_id": {"public"}, "response_type": {"code"}}, "confidential", "secret", http.StatusUnauthorized, "invalid_client", `Basic realm="par"`},
+		"invalid request":   {url.Values{"response_type": {"code"}, "redirect_uri": {"https://evil.example.com/cb"}}, "confidential", "secret", http.StatusBadRequest, "invalid_request", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := push(tc.form, tc.basicID, tc.basicSecret)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.authenticateHeader, w.Header().Get("WWW-Authenticate"))
+
+			var rErr requestError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&rErr))
+			assert.Equal(t, tc.code, rErr.Code)
+		})
+	}
+
+	// client_secret_basic
+	w := push(params, "confidential", "secret")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var resp pushedResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.True(t, strings.HasPrefix(resp.RequestURI, pg.PushedRequestURIPrefix))
+	assert.Equal(t, int64(90), resp.ExpiresIn)
+
+	// authorization request parameters are replaced with the pushed ones
+	authorize := url.Values{"client_id": {"confidential"}, "request_uri": {resp.RequestURI}, "state": {"ignored"}}
+	r := httptest.NewRequest(http.MethodGet, "/authorize?"+authorize.Encode(), nil)
+	require.NoError(t, h.Resolve(r))
+	assert.Equal(t, "code", r.FormValue("response_type"))
+	assert.Equal(t, "xyz", r.FormValue("state"))
+	assert.Equal(t, "confidential", r.FormValue("client_id"))
+
+	// request URI is single-use
+	r = httptest.NewRequest(http.MethodGet, "/authorize?"+authorize.Encode(), nil)
+	assert.Equal(t, ErrInvalidRequestURI, h.Resolve(r))
+
+	// client_secret_post, secret is not stored with the request
+	form := url.Values{"client_id": {"confidential"}, "client_secret": {"secret"}, "response_type": {"code"}}
+	w = push(form, "", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	// request URI is bound to the client
+	r = httptest.NewRequest(http.MethodGet, "/authorize?"+url.Values{"client_id": {"public"}, "request_uri": {resp.RequestURI}}.Encode(), nil)
+	assert.Equal(t, ErrInvalidRequestURI, h.Resolve(r))
+
+	r = httptest.NewRequest(http.MethodGet, "/authorize?"+url.Values{"client_id": {"confidential"}, "request_uri": {resp.RequestURI}}.Encode(), nil)
+	require.NoError(t, h.Resolve(r))
+	assert.Empty(t, r.FormValue("client_secret"))
+
+	// public client is identified by client_id only
+	w = push(url.Values{"client_id": {"public"}, "response_type": {"code"}}, "", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// requests without request_uri are left as is
+	r = httptest.NewRequest(http.MethodGet, "/authorize?response_type=token", nil)
+	require.NoError(t, h.Resolve(r))
+	assert.Equal(t, "token", r.FormValue("response_type"))
+}
+
+func TestHandler_secretRotation(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := pgx4adapter.NewPool(pool)
+	clients, err := pg.NewClientStore(
+		adapter,
+		pg.WithClientStoreTableName(fmt.Sprintf("client_par_rotation_%d", time.Now().UnixNano())),
+		pg.WithClientStoreCodec(pg.JSONCodec{}, pg.NewClientInfo),
+		pg.WithClientStoreSecretRotation(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, clients.Close())
+	}()
+
+	requests, err := pg.NewPushedRequestStore(
+		adapter,
+		pg.WithPushedRequestStoreTableName(fmt.Sprintf("pushed_request_par_rotation_%d", time.Now().UnixNano())),
+		pg.WithPushedRequestStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, requests.Close())
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, clients.Create(ctx, &pg.Client{ID: "confidential", Secret: "secret", RedirectURIs: []string{"https://client.example.com/cb"}}))
+	_, err = clients.AddSecret(ctx, "confidential", "rotated", "next", 0)
+	require.NoError(t, err)
+
+	h := NewHandler(requests, clients)
+	push := func(redirectURI, secret string) *httptest.ResponseRecorder {
+		form := url.Values{"response_type": {"code"}, "redirect_uri": {redirectURI}}
+		r := httptest.NewRequest(http.MethodPost, "/par", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("confidential", secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// redirect URI is checked against the client registered ones
+	w := push("https://evil.example.com/cb", "rotated")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var rErr requestError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rErr))
+	assert.Equal(t, "invalid_request", rErr.Code)
+
+	w = push("https://client.example.com/cb", "rotated")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = push("https://client.example.com/cb", "secret")
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
@@ -0,0 +1,25 @@
+package par
+
+import (
+	"time"
+
+	pg "github.com/vgarvardt/go-oauth2-pg/v4"
+)
+
+// Option is the configuration options type for pushed authorization request handler
+type Option func(h *Handler)
+
+// WithLogger returns option that sets pushed authorization request handler logger implementation
+func WithLogger(logger pg.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithExpiresIn returns option that sets pushed authorization request lifetime, pg.DefaultPushedRequestLifetime
+// by default. RFC 9126 recommends short lifetimes, e.g. from 5 to 600 seconds.
+func WithExpiresIn(expiresIn time.Duration) Option {
+	return func(h *Handler) {
+		h.expiresIn = expiresIn
+	}
+}
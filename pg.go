@@ -1,6 +1,41 @@
 package pg
 
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
 // Logger is the PostgreSQL store logger interface
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
+
+type jsonItem struct {
+	Data []byte `db:"data"`
+}
+
+// selectAll runs the query and decodes all resulting rows into dst slice pointer.
+// Adapter is only able to select single row, so the rows are aggregated into JSON array on the DB side
+// and dst element type must have JSON tags matching query column names.
+func selectAll(ctx context.Context, adapter pgAdapter.Adapter, dst interface{}, query string, args ...interface{}) error {
+	var item jsonItem
+	if err := adapter.SelectOne(ctx, &item, fmt.Sprintf("SELECT COALESCE(json_agg(r), '[]') AS data FROM (%s) r", query), args...); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(item.Data, dst)
+}
+
+// nullTime returns the time of the nullable column value or nil for NULL. Adapters scanning with pgx-helpers
+// can not scan NULL into *time.Time fields, so nullable timestamp columns are scanned into sql.NullTime.
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,221 @@
+package pg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// PushedRequestURIPrefix is the prefix of the request URIs generated by the pushed request store, see RFC 9126 section 2.2
+const PushedRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// DefaultPushedRequestLifetime is the default pushed authorization request lifetime when none is set on creation
+const DefaultPushedRequestLifetime = time.Minute
+
+// PushedRequestStore PostgreSQL pushed authorization requests (RFC 9126) store
+type PushedRequestStore struct {
+	adapter   pgAdapter.Adapter
+	tableName string
+	logger    Logger
+	tenantID  string
+
+	gcDisabled bool
+	gcInterval time.Duration
+	ticker     *time.Ticker
+	gcStatus   *gcStatus
+
+	initTableDisabled bool
+
+	retry  retrier
+	limits operationLimits
+}
+
+// PushedRequest pushed authorization request data item
+type PushedRequest struct {
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	RequestURI string
+	ClientID   string
+	// Params are the authorization request parameters
+	Params url.Values
+}
+
+type pushedRequestItem struct {
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	RequestURI string    `db:"request_uri"`
+	ClientID   string    `db:"client_id"`
+	Params     []byte    `db:"params"`
+}
+
+// NewPushedRequestStore creates PostgreSQL store instance
+func NewPushedRequestStore(adapter pgAdapter.Adapter, options ...PushedRequestStoreOption) (*PushedRequestStore, error) {
+	store := &PushedRequestStore{
+		adapter:    adapter,
+		tableName:  "oauth2_pushed_requests",
+		logger:     log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		gcInterval: 10 * time.Minute,
+	}
+
+	for _, o := range options {
+		o(store)
+	}
+	store.retry.logger = store.logger
+	store.limits.logger = store.logger
+
+	var err error
+	if !store.initTableDisabled {
+		err = store.initTable()
+	}
+
+	if err != nil {
+		return store, err
+	}
+
+	if !store.gcDisabled {
+		store.ticker = time.NewTicker(store.gcInterval)
+		store.gcStatus = newGCStatus(store.gcInterval)
+		go store.gc()
+	}
+
+	return store, err
+}
+
+// Close closes the store
+func (s *PushedRequestStore) Close() error {
+	if !s.gcDisabled {
+		s.ticker.Stop()
+	}
+	return nil
+}
+
+func (s *PushedRequestStore) gc() {
+	for range s.ticker.C {
+		s.clean()
+	}
+}
+
+// db returns the transaction adapter bound to the context or the store adapter
+func (s *PushedRequestStore) db(ctx context.Context) pgAdapter.Adapter {
+	return resolveAdapter(ctx, s.adapter)
+}
+
+func (s *PushedRequestStore) initTable() error {
+	ctx, done := s.limits.start(context.Background(), opMigration, "pushed request table init")
+	defer done()
+
+	return s.adapter.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id          BIGSERIAL   NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	expires_at  TIMESTAMPTZ NOT NULL,
+	request_uri TEXT        NOT NULL,
+	client_id   TEXT        NOT NULL,
+	params      JSONB       NOT NULL,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_request_uri ON %[1]s (request_uri);
+CREATE INDEX IF NOT EXISTS idx_%[1]s_expires_at ON %[1]s (expires_at);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+COMMENT ON TABLE %[1]s IS '%[2]s';
+`, s.tableName, pushedRequestTableSchema.comment()))
+}
+
+func (s *PushedRequestStore) clean() {
+	ctx, done := s.limits.start(context.Background(), opGC, "pushed requests gc")
+	defer done()
+
+	err := s.adapter.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.tableName), time.Now())
+	s.gcStatus.record(err)
+	if err != nil {
+		s.logger.Printf("Error while cleaning out outdated entities: %+v", err)
+	}
+}
+
+// Create stores the new pushed authorization request in the store or context tenant. Creation time, expiration time
+// and request URI get default values when not set, generated request URI is the random one with PushedRequestURIPrefix.
+func (s *PushedRequestStore) Create(ctx context.Context, pr *PushedRequest) error {
+	if pr.CreatedAt.IsZero() {
+		pr.CreatedAt = time.Now()
+	}
+	if pr.ExpiresAt.IsZero() {
+		pr.ExpiresAt = pr.CreatedAt.Add(DefaultPushedRequestLifetime)
+	}
+	if pr.RequestURI == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		pr.RequestURI = PushedRequestURIPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	params, err := json.Marshal(pr.Params)
+	if err != nil {
+		return err
+	}
+	if pr.Params == nil {
+		params = []byte("{}")
+	}
+
+	ctx, done := s.limits.start(ctx, opWrite, "pushed request create")
+	defer done()
+
+	return s.retry.doWrite(ctx, "pushed request create", func() error {
+		return s.db(ctx).Exec(
+			ctx,
+			fmt.Sprintf("INSERT INTO %s (tenant_id, created_at, expires_at, request_uri, client_id, params) VALUES ($1, $2, $3, $4, $5, $6)", s.tableName),
+			resolveTenant(ctx, s.tenantID),
+			pr.CreatedAt,
+			pr.ExpiresAt,
+			pr.RequestURI,
+			pr.ClientID,
+			string(params),
+		)
+	})
+}
+
+// Consume returns and deletes not expired pushed authorization request of the client, so that request URI
+// can be used once only. Returns pgAdapter.ErrNoRows for unknown, expired or already used request URI
+// and the request URI of another client or tenant. Consumption is retried on serialization failure and deadlock
+// only, as the request consumed by the statement failed with connection error would not be found by the retry.
+func (s *PushedRequestStore) Consume(ctx context.Context, requestURI, clientID string) (*PushedRequest, error) {
+	ctx, done := s.limits.start(ctx, opWrite, "pushed request consume")
+	defer done()
+
+	var item pushedRequestItem
+	if err := s.retry.doWrite(ctx, "pushed request consume", func() error {
+		return s.db(ctx).SelectOne(
+			ctx,
+			&item,
+			fmt.Sprintf("DELETE FROM %s WHERE request_uri = $1 AND client_id = $2 AND tenant_id = $3 AND expires_at > $4 RETURNING created_at, expires_at, request_uri, client_id, params", s.tableName),
+			requestURI,
+			clientID,
+			resolveTenant(ctx, s.tenantID),
+			time.Now(),
+		)
+	}); err != nil {
+		return nil, err
+	}
+
+	pr := &PushedRequest{
+		CreatedAt:  item.CreatedAt,
+		ExpiresAt:  item.ExpiresAt,
+		RequestURI: item.RequestURI,
+		ClientID:   item.ClientID,
+	}
+	if err := json.Unmarshal(item.Params, &pr.Params); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
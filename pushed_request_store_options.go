@@ -0,0 +1,73 @@
+package pg
+
+import "time"
+
+// PushedRequestStoreOption is the configuration options type for pushed request store
+type PushedRequestStoreOption func(s *PushedRequestStore)
+
+// WithPushedRequestStoreTableName returns option that sets pushed request store table name
+func WithPushedRequestStoreTableName(tableName string) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.tableName = tableName
+	}
+}
+
+// WithPushedRequestStoreGCInterval returns option that sets pushed request store garbage collection interval
+func WithPushedRequestStoreGCInterval(gcInterval time.Duration) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.gcInterval = gcInterval
+	}
+}
+
+// WithPushedRequestStoreLogger returns option that sets pushed request store logger implementation
+func WithPushedRequestStoreLogger(logger Logger) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.logger = logger
+	}
+}
+
+// WithPushedRequestStoreTenantID returns option that scopes pushed request store to the tenant,
+// tenant set to the operation context with WithTenant takes precedence
+func WithPushedRequestStoreTenantID(tenantID string) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithPushedRequestStoreGCDisabled returns option that disables pushed request store garbage collection
+func WithPushedRequestStoreGCDisabled() PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.gcDisabled = true
+	}
+}
+
+// WithPushedRequestStoreInitTableDisabled returns option that disables table creation on pushed request store instantiation
+func WithPushedRequestStoreInitTableDisabled() PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.initTableDisabled = true
+	}
+}
+
+// WithPushedRequestStoreRetryPolicy returns option that enables retries of pushed request creation and consumption
+// failed with serialization failure or deadlock. Every retry is logged with the store logger.
+func WithPushedRequestStoreRetryPolicy(policy RetryPolicy) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.retry.policy = &policy
+	}
+}
+
+// WithPushedRequestStoreTimeouts returns option that sets pushed request store operations default timeouts,
+// applied only when the operation context has no deadline
+func WithPushedRequestStoreTimeouts(timeouts OperationTimeouts) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.limits.timeouts = timeouts
+	}
+}
+
+// WithPushedRequestStoreSlowQueryThreshold returns option that enables logging of the pushed request store operations
+// that took at least threshold time
+func WithPushedRequestStoreSlowQueryThreshold(threshold time.Duration) PushedRequestStoreOption {
+	return func(s *PushedRequestStore) {
+		s.limits.slowThreshold = threshold
+	}
+}
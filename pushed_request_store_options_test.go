@@ -0,0 +1,71 @@
+package pg
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPushedRequestStoreGCDisabled(t *testing.T) {
+	store, err := NewPushedRequestStore(nil, WithPushedRequestStoreGCDisabled(), WithPushedRequestStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.gcDisabled)
+	assert.True(t, store.initTableDisabled)
+}
+
+func TestWithPushedRequestStoreTableName(t *testing.T) {
+	randomName := time.Now().String()
+
+	store, err := NewPushedRequestStore(nil, WithPushedRequestStoreTableName(randomName), WithPushedRequestStoreGCDisabled(), WithPushedRequestStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomName, store.tableName)
+}
+
+func TestWithPushedRequestStoreGCInterval(t *testing.T) {
+	randomInterval := time.Duration(rand.Int63())
+
+	store, err := NewPushedRequestStore(nil, WithPushedRequestStoreGCInterval(randomInterval), WithPushedRequestStoreGCDisabled(), WithPushedRequestStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomInterval, store.gcInterval)
+}
+
+func TestWithPushedRequestStoreTenantID(t *testing.T) {
+	randomTenant := time.Now().String()
+
+	store, err := NewPushedRequestStore(nil, WithPushedRequestStoreTenantID(randomTenant), WithPushedRequestStoreGCDisabled(), WithPushedRequestStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomTenant, store.tenantID)
+}
+
+func TestWithPushedRequestStoreLogger(t *testing.T) {
+	l := new(memoryLogger)
+
+	store, err := NewPushedRequestStore(nil, WithPushedRequestStoreLogger(l), WithPushedRequestStoreGCDisabled(), WithPushedRequestStoreInitTableDisabled())
+	require.NoError(t, err)
+
+	store.logger.Printf("log1", 1, "2", "333")
+
+	require.Equal(t, 1, len(l.formats))
+	assert.Equal(t, "log1", l.formats[0])
+}
+
+func TestWithPushedRequestStoreLimits(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5}
+	timeouts := OperationTimeouts{Write: time.Second}
+
+	store, err := NewPushedRequestStore(
+		nil,
+		WithPushedRequestStoreRetryPolicy(policy),
+		WithPushedRequestStoreTimeouts(timeouts),
+		WithPushedRequestStoreSlowQueryThreshold(time.Minute),
+		WithPushedRequestStoreGCDisabled(),
+		WithPushedRequestStoreInitTableDisabled(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &policy, store.retry.policy)
+	assert.Equal(t, timeouts, store.limits.timeouts)
+	assert.Equal(t, time.Minute, store.limits.slowThreshold)
+}
@@ -0,0 +1,130 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+	"github.com/vgarvardt/go-pg-adapter/sqladapter"
+)
+
+func TestPushedRequestStore_initTable(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		query := args.Get(1).(string)
+		// new line character is the character at position 0
+		assert.Equal(t, 1, strings.Index(query, "CREATE TABLE IF NOT EXISTS"))
+	})
+
+	store, err := NewPushedRequestStore(adapter, WithPushedRequestStoreGCDisabled())
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	adapter.AssertExpectations(t)
+}
+
+func TestPushedRequestStore_gc(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	var execCalls int
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		execCalls++
+
+		query := args.Get(1).(string)
+		assert.Equal(t, 0, strings.Index(query, "DELETE FROM"))
+	})
+
+	store, err := NewPushedRequestStore(adapter, WithPushedRequestStoreInitTableDisabled(), WithPushedRequestStoreGCInterval(time.Second))
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	time.Sleep(3 * time.Second)
+
+	// in 3 seconds we should have 2-3 gc calls
+	assert.True(t, 1 < execCalls)
+	assert.True(t, 3 >= execCalls)
+}
+
+func TestPushedRequestStore(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	conn, err := sql.Open("pgx", uri)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, conn.Close())
+	}()
+
+	for name, adapter := range map[string]pgAdapter.Adapter{
+		"pgx": pgx4adapter.NewPool(pool),
+		"sql": sqladapter.New(conn),
+	} {
+		t.Run(name, func(t *testing.T) {
+			store, err := NewPushedRequestStore(
+				adapter,
+				WithPushedRequestStoreTableName(fmt.Sprintf("pushed_request_%d", time.Now().UnixNano())),
+				WithPushedRequestStoreGCDisabled(),
+			)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, store.Close())
+			}()
+
+			ctx := context.Background()
+			params := url.Values{"response_type": {"code"}, "scope": {"openid profile"}, "redirect_uri": {"https://client.example.com/cb"}}
+
+			pr := &PushedRequest{ClientID: "client", Params: params}
+			require.NoError(t, store.Create(ctx, pr))
+			assert.True(t, strings.HasPrefix(pr.RequestURI, PushedRequestURIPrefix))
+			assert.Equal(t, DefaultPushedRequestLifetime, pr.ExpiresAt.Sub(pr.CreatedAt))
+
+			// request URI is bound to the client
+			_, err = store.Consume(ctx, pr.RequestURI, "another")
+			assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+			consumed, err := store.Consume(ctx, pr.RequestURI, "client")
+			require.NoError(t, err)
+			assert.Equal(t, params, consumed.Params)
+			assert.Equal(t, "client", consumed.ClientID)
+
+			// request URI is single-use
+			_, err = store.Consume(ctx, pr.RequestURI, "client")
+			assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+			// request URI is bound to the tenant
+			tenantCtx := WithTenant(ctx, "tenant")
+			tenant := &PushedRequest{ClientID: "client", Params: params}
+			require.NoError(t, store.Create(tenantCtx, tenant))
+			_, err = store.Consume(ctx, tenant.RequestURI, "client")
+			assert.Equal(t, pgAdapter.ErrNoRows, err)
+			_, err = store.Consume(WithTenant(ctx, "another"), tenant.RequestURI, "client")
+			assert.Equal(t, pgAdapter.ErrNoRows, err)
+			_, err = store.Consume(tenantCtx, tenant.RequestURI, "client")
+			require.NoError(t, err)
+
+			expired := &PushedRequest{ClientID: "client", CreatedAt: time.Now().Add(-time.Hour), Params: params}
+			require.NoError(t, store.Create(ctx, expired))
+			_, err = store.Consume(ctx, expired.RequestURI, "client")
+			assert.Equal(t, pgAdapter.ErrNoRows, err)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package pg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// DefaultReadYourWritesWindow is the default time the lookups of the just written entity fall back to the primary
+// when replicas do not have it yet
+const DefaultReadYourWritesWindow = 5 * time.Second
+
+// replicaSet routes lookups to the read replicas in round-robin manner. Keys of the entities written or removed
+// by the store are remembered for read-your-writes window, so that their lookups go to the primary while
+// replication may lag.
+type replicaSet struct {
+	adapters []pgAdapter.Adapter
+	next     uint32
+	window   time.Duration
+	logger   Logger
+
+	mu     sync.Mutex
+	recent map[string]time.Time
+	pruned time.Time
+}
+
+func newReplicaSet() *replicaSet {
+	return &replicaSet{window: DefaultReadYourWritesWindow, recent: make(map[string]time.Time)}
+}
+
+func (r *replicaSet) enabled() bool {
+	return len(r.adapters) > 0
+}
+
+func (r *replicaSet) pick() pgAdapter.Adapter {
+	n := atomic.AddUint32(&r.next, 1) - 1
+	return r.adapters[int(n)%len(r.adapters)]
+}
+
+// remember marks the keys as just written or removed, keys are hashed, so that credentials are not kept in memory
+func (r *replicaSet) remember(keys ...string) {
+	if !r.enabled() || r.window <= 0 {
+		return
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		if key != "" {
+			r.recent[hashSecret(key)] = now.Add(r.window)
+		}
+	}
+
+	if now.Sub(r.pruned) < r.window {
+		return
+	}
+	for key, expiresAt := range r.recent {
+		if !expiresAt.After(now) {
+			delete(r.recent, key)
+		}
+	}
+	r.pruned = now
+}
+
+func (r *replicaSet) recentlyWritten(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiresAt, ok := r.recent[hashSecret(key)]
+	return ok && expiresAt.After(time.Now())
+}
+
+// selectOne runs the lookup on a replica. Lookup of the entity written or removed within read-your-writes window
+// is made on the primary, as well as the lookup replica fails with. Lookups made within a transaction always use it.
+// Row-level security setting of the primary session is set on the replica as well.
+func (r *replicaSet) selectOne(ctx context.Context, primary pgAdapter.Adapter, key string, dst interface{}, st statement, args ...interface{}) error {
+	if _, ok := TransactionFromContext(ctx); ok || !r.enabled() || r.recentlyWritten(key) {
+		return st.selectOne(ctx, primary, dst, args...)
+	}
+
+	replica := r.pick()
+	if session, ok := primary.(sessionAdapter); ok {
+		replica = session.on(replica)
+	}
+
+	err := st.selectOne(ctx, replica, dst, args...)
+	if err == nil || err == pgAdapter.ErrNoRows || ctx.Err() != nil {
+		return err
+	}
+
+	r.logger.Printf("Error while reading from replica, falling back to primary: %+v", err)
+	return st.selectOne(ctx, primary, dst, args...)
+}
@@ -0,0 +1,151 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+func TestReplicaSet_selectOne(t *testing.T) {
+	ctx := context.Background()
+
+	primary := new(mockAdapter)
+	replica1 := new(mockAdapter)
+	replica2 := new(mockAdapter)
+
+	r := newReplicaSet()
+	r.logger = new(memoryLogger)
+
+	// no replicas configured - primary is used
+	primary.On("SelectOne", mock.Anything, mock.Anything, "primary only", mock.Anything).Return(nil).Once()
+	require.NoError(t, r.selectOne(ctx, primary, "key", nil, statement{sql: "primary only"}))
+
+	r.adapters = []pgAdapter.Adapter{replica1, replica2}
+
+	// replicas are used in round-robin manner
+	replica1.On("SelectOne", mock.Anything, mock.Anything, "round-robin", mock.Anything).Return(nil).Once()
+	replica2.On("SelectOne", mock.Anything, mock.Anything, "round-robin", mock.Anything).Return(nil).Once()
+	require.NoError(t, r.selectOne(ctx, primary, "key", nil, statement{sql: "round-robin"}))
+	require.NoError(t, r.selectOne(ctx, primary, "key", nil, statement{sql: "round-robin"}))
+
+	// transaction is always used when it is set to the context
+	tx := new(mockAdapter)
+	tx.On("SelectOne", mock.Anything, mock.Anything, "in tx", mock.Anything).Return(nil).Once()
+	require.NoError(t, r.selectOne(WithTransaction(ctx, tx), tx, "key", nil, statement{sql: "in tx"}))
+
+	// not found entity that was not written recently is not looked up on the primary
+	replica1.On("SelectOne", mock.Anything, mock.Anything, "not found", mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	assert.Equal(t, pgAdapter.ErrNoRows, r.selectOne(ctx, primary, "key", nil, statement{sql: "not found"}))
+
+	// entity that was written or removed recently is looked up on the primary
+	r.remember("key")
+	primary.On("SelectOne", mock.Anything, mock.Anything, "lagging", mock.Anything).Return(nil).Once()
+	require.NoError(t, r.selectOne(ctx, primary, "key", nil, statement{sql: "lagging"}))
+
+	// replica error falls back to the primary
+	replica2.On("SelectOne", mock.Anything, mock.Anything, "failing", mock.Anything).Return(errors.New("connection refused")).Once()
+	primary.On("SelectOne", mock.Anything, mock.Anything, "failing", mock.Anything).Return(nil).Once()
+	require.NoError(t, r.selectOne(ctx, primary, "another key", nil, statement{sql: "failing"}))
+
+	primary.AssertExpectations(t)
+	replica1.AssertExpectations(t)
+	replica2.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestReplicaSet_remember(t *testing.T) {
+	r := newReplicaSet()
+
+	// nothing is remembered without replicas
+	r.remember("key")
+	assert.False(t, r.recentlyWritten("key"))
+
+	r.adapters = []pgAdapter.Adapter{new(mockAdapter)}
+	r.window = 100 * time.Millisecond
+
+	r.remember("key", "")
+	assert.True(t, r.recentlyWritten("key"))
+	assert.False(t, r.recentlyWritten(""))
+	assert.False(t, r.recentlyWritten("another key"))
+	// keys are hashed
+	assert.NotContains(t, r.recent, "key")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, r.recentlyWritten("key"))
+
+	// outdated keys are pruned on the next write
+	r.remember("another key")
+	assert.Len(t, r.recent, 1)
+}
+
+func TestTokenStore_replicas(t *testing.T) {
+	primary := new(mockAdapter)
+	replica := new(mockAdapter)
+	tx := new(mockAdapter)
+
+	store, err := NewTokenStore(primary, WithTokenStoreReplicas(replica), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// lookups of the store bound to the transaction see the transaction changes
+	tx.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.WithTx(tx).GetByAccess(ctx, "access")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	// access token removed by refresh is not served by lagging replica
+	primary.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		reflect.ValueOf(args.Get(1)).Elem().Field(0).SetString(`["access"]`)
+	}).Once()
+	require.NoError(t, store.RemoveByRefresh(ctx, "refresh"))
+	primary.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.GetByAccess(ctx, "access")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	replica.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.GetByAccess(ctx, "another access")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	primary.AssertExpectations(t)
+	replica.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestClientStore_replicas(t *testing.T) {
+	primary := new(mockAdapter)
+	replica := new(mockAdapter)
+	tx := new(mockAdapter)
+
+	store, err := NewClientStore(primary, WithClientStoreReplicas(replica), WithClientStoreInitTableDisabled())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// lookups of the store bound to the transaction see the transaction changes
+	tx.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.WithTx(tx).GetByID(ctx, "id")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	// removed client is not served by lagging replica
+	primary.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	require.NoError(t, store.RemoveByID(ctx, "id"))
+	primary.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.GetByID(ctx, "id")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	replica.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgAdapter.ErrNoRows).Once()
+	_, err = store.GetByID(ctx, "another id")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	primary.AssertExpectations(t)
+	replica.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
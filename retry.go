@@ -0,0 +1,125 @@
+package pg
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// RetryPolicy is the retry policy of the store operations failed with transient PostgreSQL errors,
+// e.g. during failover or connection pooler restart
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of operation attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the maximum delay before the first retry, every next retry doubles it
+	InitialBackoff time.Duration
+	// MaxBackoff caps the maximum delay before retry, zero means retries are made without delay
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns retry policy that makes up to 3 attempts with 50ms initial and 1s max backoff
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+}
+
+// IsRetryable checks if the error is transient PostgreSQL error: serialization failure, deadlock,
+// server shutdown or connection exception. Error must provide its SQLSTATE code with SQLState method,
+// as both pgx and lib/pq errors do, connection errors of database/sql driver are retryable as well.
+func IsRetryable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || isRolledBack(err) {
+		return true
+	}
+
+	code := sqlState(err)
+	// admin_shutdown or connection_exception class
+	return code == "57P01" || strings.HasPrefix(code, "08")
+}
+
+// isRolledBack checks if the error is serialization failure or deadlock, PostgreSQL rolls back the statement
+// failed with them for sure, unlike the statement that failed with connection error and could have been committed
+// before the connection was lost
+func isRolledBack(err error) bool {
+	code := sqlState(err)
+	// serialization_failure, deadlock_detected
+	return code == "40001" || code == "40P01"
+}
+
+func sqlState(err error) string {
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	return pgErr.SQLState()
+}
+
+// retrier runs store operations with the retry policy, nil policy disables retries
+type retrier struct {
+	policy *RetryPolicy
+	logger Logger
+}
+
+// do runs idempotent operation, e.g. lookup or removal, retrying it on any transient error
+func (r retrier) do(ctx context.Context, operation string, fn func() error) error {
+	return r.run(ctx, operation, IsRetryable, fn)
+}
+
+// doWrite runs not idempotent write operation, e.g. insert, retrying it on serialization failure and deadlock only,
+// so that the change is never applied twice
+func (r retrier) doWrite(ctx context.Context, operation string, fn func() error) error {
+	return r.run(ctx, operation, isRolledBack, fn)
+}
+
+// run runs the operation until it succeeds, fails with not retryable error or runs out of attempts.
+// Operations made within the transaction are not retried as the failed transaction can not be continued.
+// Retry is not made if the context deadline comes before the backoff delay ends.
+func (r retrier) run(ctx context.Context, operation string, retryable func(err error) bool, fn func() error) error {
+	err := fn()
+	if r.policy == nil || err == nil {
+		return err
+	}
+	if _, ok := TransactionFromContext(ctx); ok {
+		return err
+	}
+
+	for attempt := 1; attempt < r.policy.MaxAttempts && retryable(err); attempt++ {
+		delay := r.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		r.logger.Printf("Retrying %s in %s, attempt %d of %d: %+v", operation, delay, attempt+1, r.policy.MaxAttempts, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// backoff returns random delay before the retry attempt between zero and exponentially growing maximum
+func (r retrier) backoff(attempt int) time.Duration {
+	limit := r.policy.InitialBackoff
+	for i := 1; i < attempt && limit < r.policy.MaxBackoff; i++ {
+		limit *= 2
+	}
+	if limit > r.policy.MaxBackoff {
+		limit = r.policy.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit)))
+}
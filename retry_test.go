@@ -0,0 +1,219 @@
+package pg
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "ERROR: SQLSTATE " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, code := range []string{"40001", "40P01", "57P01", "08000", "08003", "08006"} {
+		assert.True(t, IsRetryable(sqlStateError(code)), code)
+		assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", sqlStateError(code))), code)
+	}
+	for _, code := range []string{"23505", "42P01", "57014", "40002"} {
+		assert.False(t, IsRetryable(sqlStateError(code)), code)
+	}
+
+	for _, code := range []string{"40001", "40P01"} {
+		assert.True(t, isRolledBack(sqlStateError(code)), code)
+	}
+	for _, code := range []string{"57P01", "08006", "23505"} {
+		assert.False(t, isRolledBack(sqlStateError(code)), code)
+	}
+	assert.False(t, isRolledBack(driver.ErrBadConn))
+
+	assert.True(t, IsRetryable(driver.ErrBadConn))
+	assert.False(t, IsRetryable(errors.New("connection refused")))
+	assert.False(t, IsRetryable(nil))
+}
+
+func TestRetrier_do(t *testing.T) {
+	ctx := context.Background()
+	l := new(memoryLogger)
+	r := retrier{policy: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, logger: l}
+
+	newOperation := func(errs ...error) (func() error, *int) {
+		var calls int
+		return func() error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			return nil
+		}, &calls
+	}
+
+	// transient errors are retried
+	fn, calls := newOperation(sqlStateError("40001"), sqlStateError("08006"))
+	require.NoError(t, r.do(ctx, "test", fn))
+	assert.Equal(t, 3, *calls)
+	assert.Len(t, l.formats, 2)
+
+	// attempts are limited
+	fn, calls = newOperation(sqlStateError("40P01"), sqlStateError("40P01"), sqlStateError("40P01"))
+	assert.Equal(t, sqlStateError("40P01"), r.do(ctx, "test", fn))
+	assert.Equal(t, 3, *calls)
+
+	// not retryable errors are returned as is
+	fn, calls = newOperation(sqlStateError("23505"))
+	assert.Equal(t, sqlStateError("23505"), r.do(ctx, "test", fn))
+	assert.Equal(t, 1, *calls)
+
+	// operations within the transaction are not retried
+	fn, calls = newOperation(sqlStateError("40001"))
+	assert.Equal(t, sqlStateError("40001"), r.do(WithTransaction(ctx, new(mockAdapter)), "test", fn))
+	assert.Equal(t, 1, *calls)
+
+	// retry is not made when the context deadline comes before the backoff delay ends
+	slow := retrier{policy: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute}, logger: l}
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	fn, calls = newOperation(sqlStateError("57P01"))
+	assert.Equal(t, sqlStateError("57P01"), slow.do(deadlineCtx, "test", fn))
+	assert.Equal(t, 1, *calls)
+
+	// retries are disabled without the policy
+	fn, calls = newOperation(sqlStateError("40001"))
+	assert.Equal(t, sqlStateError("40001"), retrier{}.do(ctx, "test", fn))
+	assert.Equal(t, 1, *calls)
+	// writes are retried on serialization failure and deadlock only, as the write could have been
+	// committed before the connection error
+	fn, calls = newOperation(sqlStateError("40001"), sqlStateError("40P01"))
+	require.NoError(t, r.doWrite(ctx, "test", fn))
+	assert.Equal(t, 3, *calls)
+
+	for _, err := range []error{sqlStateError("08006"), sqlStateError("57P01"), driver.ErrBadConn} {
+		fn, calls = newOperation(err)
+		assert.Equal(t, err, r.doWrite(ctx, "test", fn))
+		assert.Equal(t, 1, *calls)
+	}
+}
+
+func TestRetrier_backoff(t *testing.T) {
+	r := retrier{policy: &RetryPolicy{MaxAttempts: 10, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}}
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, r.backoff(1) < 10*time.Millisecond)
+		assert.True(t, r.backoff(3) < 40*time.Millisecond)
+		assert.True(t, r.backoff(9) < 50*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Duration(0), retrier{policy: &RetryPolicy{MaxAttempts: 3}}.backoff(2))
+}
+
+func TestTokenStore_GetByAccess_retry(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("08006")).Once()
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not transient")).Once()
+
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenStoreLogger(new(memoryLogger)),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	_, err = store.GetByAccess(context.Background(), "access")
+	assert.EqualError(t, err, "not transient")
+	adapter.AssertNumberOfCalls(t, "SelectOne", 2)
+}
+
+func TestTokenStore_Create_retry(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("40001")).Once()
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("08006")).Once()
+
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenStoreLogger(new(memoryLogger)),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	// connection error is not retried as the insert could have been committed
+	assert.Equal(t, sqlStateError("08006"), store.Create(context.Background(), newBatchTokens(1)[0]))
+	adapter.AssertNumberOfCalls(t, "Exec", 2)
+}
+
+func TestTokenStore_WithTx_retry(t *testing.T) {
+	tx := new(mockAdapter)
+	tx.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("40001")).Once()
+
+	store, err := NewTokenStore(
+		new(mockAdapter),
+		WithTokenStoreRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	// failed transaction can not be continued
+	_, err = store.WithTx(tx).GetByAccess(context.Background(), "access")
+	assert.Equal(t, sqlStateError("40001"), err)
+	tx.AssertNumberOfCalls(t, "SelectOne", 1)
+	require.NotNil(t, store.retry.policy)
+}
+
+func TestTokenStore_CreateBatch_retry(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("40P01")).Once()
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("57P01")).Once()
+
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenStoreLogger(new(memoryLogger)),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	// server shutdown is not retried as the batch could have been committed
+	err = store.CreateBatch(context.Background(), newBatchTokens(3))
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []error{sqlStateError("57P01"), sqlStateError("57P01"), sqlStateError("57P01")}, batchErr.Errors)
+	adapter.AssertNumberOfCalls(t, "Exec", 2)
+}
+
+func TestTokenStore_VerifyCodeExchange_retry(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("40001")).Once()
+	adapter.On("SelectOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(sqlStateError("08006")).Once()
+
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenStoreLogger(new(memoryLogger)),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+
+	// connection error is not retried as the code could have been consumed
+	_, err = store.VerifyCodeExchange(context.Background(), "code", "", "", "client")
+	assert.Equal(t, sqlStateError("08006"), err)
+	adapter.AssertNumberOfCalls(t, "SelectOne", 2)
+}
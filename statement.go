@@ -0,0 +1,183 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// tokenLookupColumns is the explicit token table column list of the token lookups, matches tokenLookupItem fields
+const tokenLookupColumns = "id, data"
+
+// clientLookupColumns is the explicit client table column list of the client lookups
+const clientLookupColumns = `"id", "tenant_id", "secret", "domain", "disabled", "disabled_reason", "expires_at", "data"`
+
+// Preparer is the adapter able to run the statement prepared once per connection under the stable name,
+// so that repeated lookups skip query parsing and planning. PGXPool and PGXTx implement it, other adapters
+// run lookups as plain queries.
+type Preparer interface {
+	SelectOnePrepared(ctx context.Context, dst interface{}, name, query string, args ...interface{}) error
+}
+
+// statement is the query rendered once at the store construction, name is derived from the query text,
+// so the same query always gets the same name and different queries never share one
+type statement struct {
+	name string
+	sql  string
+}
+
+func newStatement(format string, a ...interface{}) statement {
+	query := strings.TrimSpace(fmt.Sprintf(format, a...))
+	sum := sha256.Sum256([]byte(query))
+	return statement{name: "oauth2_pg_" + hex.EncodeToString(sum[:8]), sql: query}
+}
+
+// selectOne runs the lookup statement, prepared if the adapter supports it
+func (st statement) selectOne(ctx context.Context, adapter pgAdapter.Adapter, dst interface{}, args ...interface{}) error {
+	if p, ok := adapter.(Preparer); ok {
+		return p.SelectOnePrepared(ctx, dst, st.name, st.sql, args...)
+	}
+	return adapter.SelectOne(ctx, dst, st.sql, args...)
+}
+
+// tokenStatements are the token store hot path statements
+type tokenStatements struct {
+	getByCode    statement
+	getByAccess  statement
+	getByRefresh statement
+	create       string
+	remove       map[string]string
+}
+
+func newTokenStatements(tableName string, refreshIdleTimeout bool) tokenStatements {
+	st := tokenStatements{
+		getByCode:    newStatement("SELECT %s FROM %s WHERE code = $1 AND tenant_id = $2 AND (code_expires_at IS NULL OR code_expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3)", tokenLookupColumns, tableName),
+		getByAccess:  newStatement("SELECT %s FROM %s WHERE access = $1 AND tenant_id = $2 AND (access_expires_at IS NULL OR access_expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3)", tokenLookupColumns, tableName),
+		getByRefresh: newStatement("SELECT %s FROM %s WHERE refresh = $1 AND tenant_id = $2 AND (refresh_expires_at IS NULL OR refresh_expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3)", tokenLookupColumns, tableName),
+		create:       fmt.Sprintf("INSERT INTO %s (tenant_id, created_at, expires_at, code, access, refresh, client_id, user_id, idle_expires_at, code_expires_at, access_expires_at, refresh_expires_at, code_challenge, code_challenge_method, nonce, redirect_uri, data) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)", tableName),
+		remove:       make(map[string]string, 3),
+	}
+	if refreshIdleTimeout {
+		// extend inactivity deadline on every refresh token lookup, but never past the absolute expiration
+		st.getByRefresh = newStatement("UPDATE %s SET idle_expires_at = LEAST($4, refresh_expires_at) WHERE refresh = $1 AND tenant_id = $2 AND (refresh_expires_at IS NULL OR refresh_expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3) RETURNING %s", tableName, tokenLookupColumns)
+	}
+	for _, column := range []string{"code", "access", "refresh"} {
+		st.remove[column] = fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND tenant_id = $2", tableName, column)
+	}
+	return st
+}
+
+// clientStatements are the client store hot path statements
+type clientStatements struct {
+	getByID statement
+	create  string
+	update  string
+	remove  string
+}
+
+func newClientStatements(tableName, secretsTableName string, secretRotation bool) clientStatements {
+	st := clientStatements{
+		getByID: newStatement(`SELECT %s FROM "%s" WHERE "id" = $1 AND "tenant_id" = $2`, clientLookupColumns, tableName),
+		create: fmt.Sprintf(`
+INSERT INTO %s ("id", "tenant_id", "secret", "domain", "public", "user_id", "redirect_uris", "grant_types", "scopes", "access_token_lifetime", "refresh_token_lifetime", "data")
+VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9::jsonb, $10, $11, $12)`, tableName),
+		update: fmt.Sprintf(`
+UPDATE %s SET
+	"secret" = $1, "domain" = $2, "public" = $3, "user_id" = $4, "redirect_uris" = $5::jsonb, "grant_types" = $6::jsonb,
+	"scopes" = $7::jsonb, "access_token_lifetime" = $8, "refresh_token_lifetime" = $9, "data" = $10
+WHERE "id" = $11 AND "tenant_id" = $12`, tableName),
+		remove: fmt.Sprintf(`DELETE FROM %s WHERE "id" = $1 AND "tenant_id" = $2`, tableName),
+	}
+	if secretRotation {
+		st.getByID = newStatement(`
+SELECT c."id", c."tenant_id", c."secret", c."domain", c."disabled", c."disabled_reason", c."expires_at", c."data", COALESCE((
+	SELECT json_agg(s."secret_hash") FROM %[2]s s
+	WHERE s."tenant_id" = c."tenant_id" AND s."client_id" = c."id" AND (s."expires_at" IS NULL OR s."expires_at" > $3)
+), '[]') AS "secret_hashes"
+FROM %[1]s c WHERE c."id" = $1 AND c."tenant_id" = $2`, tableName, secretsTableName)
+	}
+	return st
+}
+
+// deviceCodeStatements are the device code store statements
+type deviceCodeStatements struct {
+	create          string
+	getByDeviceCode statement
+	getByUserCode   statement
+	resolve         string
+	poll            string
+	remove          string
+	clean           string
+}
+
+func newDeviceCodeStatements(tableName string) deviceCodeStatements {
+	return deviceCodeStatements{
+		create:          fmt.Sprintf("INSERT INTO %s (tenant_id, created_at, expires_at, device_code, user_code, client_id, scope, poll_interval, status, user_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", tableName),
+		getByDeviceCode: newStatement("SELECT %s FROM %s WHERE device_code = $1 AND tenant_id = $2", deviceCodeColumns, tableName),
+		getByUserCode:   newStatement("SELECT %s FROM %s WHERE user_code = $1 AND tenant_id = $2", deviceCodeColumns, tableName),
+		resolve:         fmt.Sprintf("UPDATE %s SET status = $1, user_id = $2 WHERE user_code = $3 AND tenant_id = $4 AND status = $5 AND expires_at > $6 RETURNING %s", tableName, deviceCodeColumns),
+		// conditions of consumed and polled are mutually exclusive, so that the row is never modified twice
+		poll: fmt.Sprintf(`
+WITH prev AS (
+	SELECT id AS prev_id, COALESCE(last_polled_at + poll_interval * INTERVAL '1 second' > $2, false) AS slow_down
+	FROM %[1]s WHERE device_code = $1 AND tenant_id = $4 FOR UPDATE
+), consumed AS (
+	DELETE FROM %[1]s t USING prev
+	WHERE t.id = prev.prev_id AND t.status = $5 AND t.expires_at > $2
+	RETURNING %[2]s, slow_down
+), polled AS (
+	UPDATE %[1]s t SET
+		last_polled_at = $2,
+		poll_interval = CASE WHEN prev.slow_down THEN t.poll_interval + $3 ELSE t.poll_interval END
+	FROM prev WHERE t.id = prev.prev_id AND NOT (t.status = $5 AND t.expires_at > $2)
+	RETURNING %[2]s, slow_down
+)
+SELECT %[2]s, slow_down FROM consumed UNION ALL SELECT %[2]s, slow_down FROM polled`, tableName, deviceCodeColumns),
+		remove: fmt.Sprintf("DELETE FROM %s WHERE device_code = $1 AND tenant_id = $2", tableName),
+		clean:  fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", tableName),
+	}
+}
+
+// consentStatements are the consent store statements
+type consentStatements struct {
+	grant        string
+	get          statement
+	list         string
+	revoke       string
+	removeTokens string
+}
+
+func newConsentStatements(tableName, tokenTableName string) consentStatements {
+	return consentStatements{
+		grant: fmt.Sprintf(`
+INSERT INTO %[1]s (tenant_id, user_id, client_id, scope, created_at, updated_at, expires_at) VALUES ($6, $1, $2, $3, $4, $4, $5)
+ON CONFLICT (tenant_id, user_id, client_id) DO UPDATE SET
+	scope = CASE WHEN %[1]s.revoked_at IS NULL AND (%[1]s.expires_at IS NULL OR %[1]s.expires_at > $4)
+		THEN (
+			SELECT array_to_string(array_agg(DISTINCT s ORDER BY s), ' ')
+			FROM unnest(string_to_array(%[1]s.scope || ' ' || EXCLUDED.scope, ' ')) s
+			WHERE s <> ''
+		)
+		ELSE EXCLUDED.scope END,
+	created_at = CASE WHEN %[1]s.revoked_at IS NULL AND (%[1]s.expires_at IS NULL OR %[1]s.expires_at > $4)
+		THEN %[1]s.created_at
+		ELSE EXCLUDED.created_at END,
+	updated_at = EXCLUDED.updated_at,
+	expires_at = EXCLUDED.expires_at,
+	revoked_at = NULL`, tableName),
+		get:  newStatement("SELECT %s FROM %s WHERE user_id = $1 AND client_id = $2 AND tenant_id = $3 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $4)", consentColumns, tableName),
+		list: fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 AND tenant_id = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $3) ORDER BY client_id", consentColumns, tableName),
+		revoke: fmt.Sprintf(`
+WITH revoked AS (
+	UPDATE %s SET revoked_at = $3, updated_at = $3
+	WHERE user_id = $1 AND client_id = $2 AND tenant_id = $4 AND revoked_at IS NULL
+	RETURNING tenant_id, user_id, client_id
+)`, tableName),
+		removeTokens: fmt.Sprintf(`DELETE FROM %s t USING revoked
+WHERE t.user_id = revoked.user_id AND t.client_id = revoked.client_id AND t.tenant_id = revoked.tenant_id`, tokenTableName),
+	}
+}
@@ -0,0 +1,164 @@
+package pg
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+type mockPreparer struct {
+	mockAdapter
+}
+
+func (m *mockPreparer) SelectOnePrepared(ctx context.Context, dst interface{}, name, query string, args ...interface{}) error {
+	mArgs := m.Called(ctx, dst, name, query, args)
+	return mArgs.Error(0)
+}
+
+func TestNewStatement(t *testing.T) {
+	st1 := newStatement("SELECT %s FROM %s", tokenLookupColumns, "oauth2_tokens")
+	st2 := newStatement("SELECT %s FROM %s", tokenLookupColumns, "oauth2_tokens")
+	st3 := newStatement("SELECT %s FROM %s", tokenLookupColumns, "another_tokens")
+
+	assert.Equal(t, st1, st2)
+	assert.NotEqual(t, st1.name, st3.name)
+	assert.True(t, strings.HasPrefix(st1.name, "oauth2_pg_"))
+	assert.LessOrEqual(t, len(st1.name), 63, "statement name must fit PostgreSQL identifier length")
+}
+
+func TestNewTokenStatements(t *testing.T) {
+	st := newTokenStatements("oauth2_tokens", false)
+	for _, lookup := range []statement{st.getByCode, st.getByAccess, st.getByRefresh} {
+		assert.NotContains(t, lookup.sql, "*")
+		assert.Contains(t, lookup.sql, tokenLookupColumns)
+	}
+	assert.True(t, strings.HasPrefix(st.getByRefresh.sql, "SELECT"))
+	assert.Equal(t, "DELETE FROM oauth2_tokens WHERE access = $1 AND tenant_id = $2", st.remove["access"])
+
+	idle := newTokenStatements("oauth2_tokens", true)
+	assert.True(t, strings.HasPrefix(idle.getByRefresh.sql, "UPDATE"))
+	assert.NotEqual(t, st.getByRefresh.name, idle.getByRefresh.name)
+}
+
+func TestNewDeviceCodeAndConsentStatements(t *testing.T) {
+	dc := newDeviceCodeStatements("oauth2_device_codes")
+	for _, lookup := range []statement{dc.getByDeviceCode, dc.getByUserCode} {
+		assert.NotContains(t, lookup.sql, "*")
+		assert.Contains(t, lookup.sql, deviceCodeColumns)
+	}
+	assert.NotEqual(t, dc.getByDeviceCode.name, dc.getByUserCode.name)
+	assert.Equal(t, "DELETE FROM oauth2_device_codes WHERE device_code = $1 AND tenant_id = $2", dc.remove)
+
+	consent := newConsentStatements("oauth2_consents", "oauth2_tokens")
+	assert.Contains(t, consent.get.sql, consentColumns)
+	assert.Contains(t, consent.removeTokens, "DELETE FROM oauth2_tokens")
+}
+
+func TestStatement_selectOne(t *testing.T) {
+	ctx := context.Background()
+	st := newStatement("SELECT 1")
+
+	plain := new(mockAdapter)
+	plain.On("SelectOne", mock.Anything, mock.Anything, st.sql, mock.Anything).Return(nil).Once()
+	require.NoError(t, st.selectOne(ctx, plain, nil))
+
+	prepared := new(mockPreparer)
+	prepared.On("SelectOnePrepared", mock.Anything, mock.Anything, st.name, st.sql, mock.Anything).Return(nil).Once()
+	require.NoError(t, st.selectOne(ctx, prepared, nil))
+
+	plain.AssertExpectations(t)
+	prepared.AssertExpectations(t)
+}
+
+func TestTokenStore_PreparedStatements(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := NewPGXPool(pool)
+	store, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	ctx := context.Background()
+	info := newBatchTokens(1)[0]
+	require.NoError(t, store.Create(ctx, info))
+
+	// lookups on the same connection reuse the statement prepared by the first one
+	for i := 0; i < 3; i++ {
+		got, err := store.GetByAccess(ctx, info.GetAccess())
+		require.NoError(t, err)
+		assert.Equal(t, info.GetUserID(), got.GetUserID())
+	}
+
+	_, err = store.GetByAccess(ctx, "unknown")
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	// statements prepared within transaction are scoped to its connection as well
+	require.NoError(t, adapter.InTx(ctx, func(tx pgAdapter.Adapter) error {
+		got, err := store.WithTx(tx).GetByAccess(ctx, info.GetAccess())
+		if err == nil {
+			assert.Equal(t, info.GetUserID(), got.GetUserID())
+		}
+		return err
+	}))
+}
+
+// plainAdapter hides Preparer implementation of the adapter, so that statements run as plain queries
+type plainAdapter struct {
+	pgAdapter.Adapter
+}
+
+// BenchmarkTokenStore_GetByAccess compares access token lookups with statements prepared once per connection
+// and with queries parsed and planned on every call
+func BenchmarkTokenStore_GetByAccess(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		adapter func(pool *pgxpool.Pool) pgAdapter.Adapter
+	}{
+		{"prepared", func(pool *pgxpool.Pool) pgAdapter.Adapter { return NewPGXPool(pool) }},
+		{"unprepared", func(pool *pgxpool.Pool) pgAdapter.Adapter { return plainAdapter{NewPGXPool(pool)} }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			cfg, err := pgxpool.ParseConfig(uri)
+			require.NoError(b, err)
+			// pgx statement cache prepares plain queries as well, so it is disabled to see the difference
+			cfg.ConnConfig.BuildStatementCache = nil
+
+			pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+			require.NoError(b, err)
+			defer pool.Close()
+
+			store, err := NewTokenStore(bc.adapter(pool), WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+			require.NoError(b, err)
+			defer func() {
+				assert.NoError(b, store.Close())
+			}()
+
+			ctx := context.Background()
+			info := models.NewToken()
+			info.SetAccess("benchmark access")
+			info.SetAccessCreateAt(time.Now())
+			info.SetAccessExpiresIn(time.Hour)
+			require.NoError(b, store.Create(ctx, info))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := store.GetByAccess(ctx, info.GetAccess()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
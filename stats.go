@@ -0,0 +1,200 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// Statistics defaults
+const (
+	// DefaultStatsTopLimit is the default number of clients and users in the statistics top lists
+	DefaultStatsTopLimit = 10
+	// DefaultStatsExactCountLimit is the default table rows estimate up to which statistics are counted exactly,
+	// bigger tables are sampled
+	DefaultStatsExactCountLimit = 1000000
+)
+
+// TableStats is the store table size statistics, rows number is PostgreSQL planner estimate
+type TableStats struct {
+	Rows      int64 `json:"rows"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// TokenCount is the number of tokens of the client or the user
+type TokenCount struct {
+	ID    string `json:"id"`
+	Count int64  `json:"count"`
+}
+
+// TokenStats is the token store statistics, counts are for the store or context tenant
+type TokenStats struct {
+	// Codes is the number of not expired authorization codes
+	Codes int64 `json:"codes"`
+	// AccessTokens is the number of not expired access tokens
+	AccessTokens int64 `json:"access_tokens"`
+	// RefreshTokens is the number of not expired refresh tokens
+	RefreshTokens int64 `json:"refresh_tokens"`
+	// Expired is the number of expired rows not yet removed by garbage collection
+	Expired int64 `json:"expired"`
+	// TopClients are the clients having the most not expired rows
+	TopClients []TokenCount `json:"top_clients"`
+	// TopUsers are the users having the most not expired rows
+	TopUsers []TokenCount `json:"top_users"`
+	// Table is the whole table statistics, regardless of the tenant
+	Table TableStats `json:"table"`
+	// Estimated is set when the counts are estimated from the table sample as the table is too big to count exactly
+	Estimated bool `json:"estimated"`
+}
+
+// ClientStats is the client store statistics, counts are for the store or context tenant
+type ClientStats struct {
+	// Clients is the total number of clients
+	Clients int64 `json:"clients"`
+	// Public is the number of public clients
+	Public int64 `json:"public"`
+	// Disabled is the number of disabled clients
+	Disabled int64 `json:"disabled"`
+	// Expired is the number of expired clients that are not disabled
+	Expired int64 `json:"expired"`
+	// Table is the whole table statistics, regardless of the tenant
+	Table TableStats `json:"table"`
+	// Estimated is set when the counts are estimated from the table sample as the table is too big to count exactly
+	Estimated bool `json:"estimated"`
+}
+
+// statsSampler counts statistics exactly for small tables and from the random table sample for the big ones
+type statsSampler struct {
+	topLimit        int
+	exactCountLimit int64
+}
+
+func newStatsSampler() statsSampler {
+	return statsSampler{topLimit: DefaultStatsTopLimit, exactCountLimit: DefaultStatsExactCountLimit}
+}
+
+// tableStats returns table size and rows estimate from pg_class
+func (s statsSampler) tableStats(ctx context.Context, adapter pgAdapter.Adapter, tableName string) (TableStats, error) {
+	var item struct {
+		Rows      int64 `db:"rows"`
+		SizeBytes int64 `db:"size_bytes"`
+	}
+	// reltuples is negative for the tables that were never vacuumed or analyzed
+	err := adapter.SelectOne(ctx, &item, `
+SELECT GREATEST(c.reltuples, 0)::bigint AS rows, pg_total_relation_size(c.oid) AS size_bytes
+FROM pg_class c WHERE c.oid = to_regclass($1)`, tableName)
+
+	return TableStats{Rows: item.Rows, SizeBytes: item.SizeBytes}, err
+}
+
+// sample returns table sampling clause and the factor sampled counts are scaled with,
+// sampling clause is empty when the table is small enough to count exactly
+func (s statsSampler) sample(table TableStats) (string, float64) {
+	if s.exactCountLimit <= 0 || table.Rows <= s.exactCountLimit {
+		return "", 1
+	}
+
+	percent := 100 * float64(s.exactCountLimit) / float64(table.Rows)
+	return fmt.Sprintf("TABLESAMPLE SYSTEM (%f)", percent), 100 / percent
+}
+
+func scaleCount(count int64, scale float64) int64 {
+	return int64(math.Round(float64(count) * scale))
+}
+
+// notExpired returns token table condition of the expiration column and inactivity deadline being in the future,
+// current time is the second query parameter
+func notExpired(column string) string {
+	return fmt.Sprintf("(%[1]s IS NULL OR %[1]s > $2) AND (idle_expires_at IS NULL OR idle_expires_at > $2)", column)
+}
+
+// Stats returns token store statistics, see TokenStats
+func (s *TokenStore) Stats(ctx context.Context) (*TokenStats, error) {
+	ctx, done := s.limits.start(ctx, opRead, "token stats")
+	defer done()
+
+	table, err := s.stats.tableStats(ctx, s.db(ctx), s.tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	sample, scale := s.stats.sample(table)
+	stats := &TokenStats{Table: table, Estimated: sample != ""}
+	args := []interface{}{resolveTenant(ctx, s.tenantID), time.Now()}
+
+	var counts struct {
+		Codes         int64 `db:"codes"`
+		AccessTokens  int64 `db:"access_tokens"`
+		RefreshTokens int64 `db:"refresh_tokens"`
+		Expired       int64 `db:"expired"`
+	}
+	if err := s.db(ctx).SelectOne(ctx, &counts, fmt.Sprintf(`
+SELECT
+	count(*) FILTER (WHERE code <> '' AND %[3]s) AS codes,
+	count(*) FILTER (WHERE access <> '' AND %[4]s) AS access_tokens,
+	count(*) FILTER (WHERE refresh <> '' AND %[5]s) AS refresh_tokens,
+	count(*) FILTER (WHERE expires_at <= $2 OR idle_expires_at <= $2) AS expired
+FROM %[1]s %[2]s WHERE tenant_id = $1`, s.tableName, sample, notExpired("code_expires_at"), notExpired("access_expires_at"), notExpired("refresh_expires_at")), args...); err != nil {
+		return nil, err
+	}
+	stats.Codes = scaleCount(counts.Codes, scale)
+	stats.AccessTokens = scaleCount(counts.AccessTokens, scale)
+	stats.RefreshTokens = scaleCount(counts.RefreshTokens, scale)
+	stats.Expired = scaleCount(counts.Expired, scale)
+
+	for _, top := range []struct {
+		column string
+		dst    *[]TokenCount
+	}{{"client_id", &stats.TopClients}, {"user_id", &stats.TopUsers}} {
+		if err := selectAll(ctx, s.db(ctx), top.dst, fmt.Sprintf(`
+SELECT %[3]s AS id, count(*) AS count FROM %[1]s %[2]s
+WHERE tenant_id = $1 AND %[3]s <> '' AND %[4]s
+GROUP BY %[3]s ORDER BY count DESC, %[3]s LIMIT %[5]d`, s.tableName, sample, top.column, notExpired("expires_at"), s.stats.topLimit), args...); err != nil {
+			return nil, err
+		}
+		for i := range *top.dst {
+			(*top.dst)[i].Count = scaleCount((*top.dst)[i].Count, scale)
+		}
+	}
+
+	return stats, nil
+}
+
+// Stats returns client store statistics, see ClientStats
+func (s *ClientStore) Stats(ctx context.Context) (*ClientStats, error) {
+	ctx, done := s.limits.start(ctx, opRead, "client stats")
+	defer done()
+
+	table, err := s.stats.tableStats(ctx, s.db(ctx), s.tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	sample, scale := s.stats.sample(table)
+	stats := &ClientStats{Table: table, Estimated: sample != ""}
+
+	var counts struct {
+		Clients  int64 `db:"clients"`
+		Public   int64 `db:"public"`
+		Disabled int64 `db:"disabled"`
+		Expired  int64 `db:"expired"`
+	}
+	if err := s.db(ctx).SelectOne(ctx, &counts, fmt.Sprintf(`
+SELECT
+	count(*) AS clients,
+	count(*) FILTER (WHERE "public") AS public,
+	count(*) FILTER (WHERE "disabled") AS disabled,
+	count(*) FILTER (WHERE NOT "disabled" AND "expires_at" <= $2) AS expired
+FROM %s %s WHERE "tenant_id" = $1`, s.tableName, sample), resolveTenant(ctx, s.tenantID), time.Now()); err != nil {
+		return nil, err
+	}
+	stats.Clients = scaleCount(counts.Clients, scale)
+	stats.Public = scaleCount(counts.Public, scale)
+	stats.Disabled = scaleCount(counts.Disabled, scale)
+	stats.Expired = scaleCount(counts.Expired, scale)
+
+	return stats, nil
+}
@@ -0,0 +1,115 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestStatsSampler_sample(t *testing.T) {
+	s := statsSampler{exactCountLimit: 1000}
+
+	sample, scale := s.sample(TableStats{Rows: 1000})
+	assert.Equal(t, "", sample)
+	assert.Equal(t, float64(1), scale)
+
+	sample, scale = s.sample(TableStats{Rows: 100000})
+	assert.Equal(t, "TABLESAMPLE SYSTEM (1.000000)", sample)
+	assert.Equal(t, float64(100), scale)
+	assert.Equal(t, int64(1200), scaleCount(12, scale))
+
+	// sampling is disabled
+	sample, scale = statsSampler{}.sample(TableStats{Rows: 100000})
+	assert.Equal(t, "", sample)
+	assert.Equal(t, float64(1), scale)
+}
+
+func TestTokenStore_Stats(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	ctx := context.Background()
+
+	store, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled(), WithTokenStoreStatsLimits(1, 0))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	newToken := func(clientID, userID string, expiresIn time.Duration) *models.Token {
+		token := models.NewToken()
+		token.SetClientID(clientID)
+		token.SetUserID(userID)
+		token.SetAccess(fmt.Sprintf("access %s", time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(expiresIn)
+		return token
+	}
+
+	require.NoError(t, store.Create(ctx, newToken("client-a", "user-a", time.Hour)))
+	require.NoError(t, store.Create(ctx, newToken("client-a", "user-b", time.Hour)))
+	require.NoError(t, store.Create(ctx, newToken("client-b", "user-b", time.Hour)))
+	require.NoError(t, store.Create(ctx, newToken("client-b", "user-b", time.Nanosecond)))
+
+	code := models.NewToken()
+	code.SetClientID("client-b")
+	code.SetCode(fmt.Sprintf("code %s", time.Now().String()))
+	code.SetCodeCreateAt(time.Now())
+	code.SetCodeExpiresIn(time.Minute)
+	require.NoError(t, store.Create(ctx, code))
+
+	refresh := newToken("client-b", "user-a", time.Hour)
+	refresh.SetRefresh(fmt.Sprintf("refresh %s", time.Now().String()))
+	refresh.SetRefreshCreateAt(time.Now())
+	refresh.SetRefreshExpiresIn(time.Hour)
+	require.NoError(t, store.Create(ctx, refresh))
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Codes)
+	assert.Equal(t, int64(4), stats.AccessTokens)
+	assert.Equal(t, int64(1), stats.RefreshTokens)
+	assert.Equal(t, int64(1), stats.Expired)
+	assert.Equal(t, []TokenCount{{ID: "client-b", Count: 3}}, stats.TopClients)
+	assert.Equal(t, []TokenCount{{ID: "user-a", Count: 2}}, stats.TopUsers)
+	assert.True(t, stats.Table.SizeBytes > 0)
+	assert.False(t, stats.Estimated)
+}
+
+func TestClientStore_Stats(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	adapter := pgx4adapter.NewPool(pgXConnPool)
+	ctx := context.Background()
+
+	store, err := NewClientStore(adapter, WithClientStoreTableName(generateClientTableName()))
+	require.NoError(t, err)
+
+	for i, public := range []bool{true, false, false, false} {
+		require.NoError(t, store.Create(ctx, &models.Client{ID: fmt.Sprintf("client-%d", i), Public: public}))
+	}
+	require.NoError(t, store.Disable(ctx, "client-1", "test", false))
+	expiredAt := time.Now().Add(-time.Minute)
+	require.NoError(t, store.SetExpiresAt(ctx, "client-2", &expiredAt))
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Clients)
+	assert.Equal(t, int64(1), stats.Public)
+	assert.Equal(t, int64(1), stats.Disabled)
+	assert.Equal(t, int64(1), stats.Expired)
+	assert.True(t, stats.Table.SizeBytes > 0)
+	assert.False(t, stats.Estimated)
+}
@@ -0,0 +1,119 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// TenantSetting is the name of the PostgreSQL session setting row-level security policies are keyed on
+const TenantSetting = "oauth2_pg.tenant_id"
+
+// AllTenantsSetting is the name of the PostgreSQL session setting that opens the rows of all tenants
+// to row-level security policies. Stores set it for garbage collection and usage flush, that process
+// the whole table.
+const AllTenantsSetting = "oauth2_pg.all_tenants"
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a copy of the context that scopes store operations to the given tenant.
+// Tenant from the context takes precedence over the one configured for the store.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant stored in the context, if any
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantCtxKey{}).(string)
+	return tenantID, ok
+}
+
+func resolveTenant(ctx context.Context, storeTenantID string) string {
+	if tenantID, ok := TenantFromContext(ctx); ok {
+		return tenantID
+	}
+	return storeTenantID
+}
+
+// EnableRowLevelSecurity enables PostgreSQL row-level security on the table, so that only the rows
+// with tenant_id matching TenantSetting session setting are visible and writable, or all the rows
+// when AllTenantsSetting session setting is on.
+// Superusers and roles with BYPASSRLS attribute are not affected by the policy.
+func EnableRowLevelSecurity(ctx context.Context, adapter pgAdapter.Adapter, tableName string) error {
+	return adapter.Exec(ctx, fmt.Sprintf(`
+ALTER TABLE %[1]s ENABLE ROW LEVEL SECURITY;
+ALTER TABLE %[1]s FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS %[1]s_tenant_isolation ON %[1]s;
+CREATE POLICY %[1]s_tenant_isolation ON %[1]s
+	USING (tenant_id = current_setting('%[2]s', true) OR current_setting('%[3]s', true) = 'on')
+	WITH CHECK (tenant_id = current_setting('%[2]s', true) OR current_setting('%[3]s', true) = 'on');
+`, tableName, TenantSetting, AllTenantsSetting))
+}
+
+// SetTenantSession sets TenantSetting session setting used by row-level security policies.
+// When local is true the setting lasts until the end of the current transaction only.
+// Stores with row-level security enabled set it for every operation, call it for own queries
+// on the same connection or transaction.
+func SetTenantSession(ctx context.Context, adapter pgAdapter.Adapter, tenantID string, local bool) error {
+	return adapter.Exec(ctx, "SELECT set_config($1, $2, $3)", TenantSetting, tenantID, local)
+}
+
+// sessionAdapter runs every statement in a transaction with the setting set locally, so that row-level security
+// policies see the setting whatever pool connection the statement runs on. Statements of the adapter that is not
+// Transactor, e.g. the transaction adapter, run right after the setting on the adapter itself.
+type sessionAdapter struct {
+	adapter pgAdapter.Adapter
+	setting string
+	value   string
+}
+
+// rlsSession returns the adapter that sets the setting for every statement if row-level security is enabled,
+// or the adapter as is otherwise
+func rlsSession(adapter pgAdapter.Adapter, enabled bool, setting, value string) pgAdapter.Adapter {
+	if !enabled {
+		return adapter
+	}
+	return sessionAdapter{adapter: adapter, setting: setting, value: value}
+}
+
+// on returns the adapter that sets the same setting on another adapter, e.g. a read replica
+func (a sessionAdapter) on(adapter pgAdapter.Adapter) pgAdapter.Adapter {
+	return sessionAdapter{adapter: adapter, setting: a.setting, value: a.value}
+}
+
+// InTx runs fn in a new transaction with the setting set
+func (a sessionAdapter) InTx(ctx context.Context, fn func(tx pgAdapter.Adapter) error) error {
+	set := func(tx pgAdapter.Adapter) error {
+		if err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", a.setting, a.value); err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+
+	if t, ok := a.adapter.(Transactor); ok {
+		return t.InTx(ctx, set)
+	}
+	return set(a.adapter)
+}
+
+// Exec runs a query with the setting set
+func (a sessionAdapter) Exec(ctx context.Context, query string, args ...interface{}) error {
+	return a.InTx(ctx, func(tx pgAdapter.Adapter) error {
+		return tx.Exec(ctx, query, args...)
+	})
+}
+
+// SelectOne runs a select query with the setting set
+func (a sessionAdapter) SelectOne(ctx context.Context, dst interface{}, query string, args ...interface{}) error {
+	return a.InTx(ctx, func(tx pgAdapter.Adapter) error {
+		return tx.SelectOne(ctx, dst, query, args...)
+	})
+}
+
+// SelectOnePrepared runs the statement with the setting set, prepared if the transaction adapter supports it
+func (a sessionAdapter) SelectOnePrepared(ctx context.Context, dst interface{}, name, query string, args ...interface{}) error {
+	return a.InTx(ctx, func(tx pgAdapter.Adapter) error {
+		return statement{name: name, sql: query}.selectOne(ctx, tx, dst, args...)
+	})
+}
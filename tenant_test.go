@@ -0,0 +1,139 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+func TestRowLevelSecurity(t *testing.T) {
+	ctx := context.Background()
+	tokenTableName, clientTableName := generateTokenTableName(), generateClientTableName()
+
+	ownerPool, err := pgxpool.Connect(ctx, uri)
+	require.NoError(t, err)
+	defer ownerPool.Close()
+
+	ownerTokenStore, err := NewTokenStore(
+		NewPGXPool(ownerPool),
+		WithTokenStoreTableName(tokenTableName),
+		WithTokenStoreRowLevelSecurity(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, ownerTokenStore.Close())
+	}()
+
+	_, err = NewClientStore(NewPGXPool(ownerPool), WithClientStoreTableName(clientTableName), WithClientStoreRowLevelSecurity())
+	require.NoError(t, err)
+
+	// policies do not apply to the superuser tests connect with, so the stores under test run with own role
+	role := "oauth2_pg_rls_test"
+	_, err = ownerPool.Exec(ctx, fmt.Sprintf(`
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '%[1]s') THEN
+		CREATE ROLE %[1]s;
+	END IF;
+END $$;
+GRANT SELECT, INSERT, UPDATE, DELETE ON %[2]s, %[3]s TO %[1]s;
+GRANT USAGE ON SEQUENCE %[2]s_id_seq TO %[1]s;
+`, role, tokenTableName, clientTableName))
+	require.NoError(t, err)
+
+	config, err := pgxpool.ParseConfig(uri)
+	require.NoError(t, err)
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SET ROLE "+role)
+		return err
+	}
+	pool, err := pgxpool.ConnectConfig(ctx, config)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	tokenStore, err := NewTokenStore(
+		NewPGXPool(pool),
+		WithTokenStoreTableName(tokenTableName),
+		WithTokenStoreInitTableDisabled(),
+		WithTokenStoreRowLevelSecurity(),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	clientStore, err := NewClientStore(
+		NewPGXPool(pool),
+		WithClientStoreTableName(clientTableName),
+		WithClientStoreInitTableDisabled(),
+		WithClientStoreRowLevelSecurity(),
+	)
+	require.NoError(t, err)
+
+	tenantA, tenantB := WithTenant(ctx, "tenant-a"), WithTenant(ctx, "tenant-b")
+
+	// client ids are unique per tenant
+	require.NoError(t, clientStore.Create(tenantA, &models.Client{ID: "client", Secret: "secret a"}))
+	require.NoError(t, clientStore.Create(tenantB, &models.Client{ID: "client", Secret: "secret b", Public: true}))
+
+	clientStats, err := clientStore.Stats(tenantA)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), clientStats.Clients)
+	assert.Equal(t, int64(0), clientStats.Public)
+
+	newToken := func(expiresIn time.Duration) *models.Token {
+		token := models.NewToken()
+		token.SetClientID("client")
+		token.SetAccess(fmt.Sprintf("access %s", time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(expiresIn)
+		return token
+	}
+
+	tokenA := newToken(time.Hour)
+	require.NoError(t, tokenStore.Create(tenantA, tokenA))
+	require.NoError(t, tokenStore.Create(tenantB, newToken(time.Hour)))
+	require.NoError(t, tokenStore.Create(tenantB, newToken(time.Nanosecond)))
+
+	_, err = tokenStore.GetByAccess(tenantB, tokenA.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	tokenStats, err := tokenStore.Stats(tenantA)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tokenStats.AccessTokens)
+
+	tokenStats, err = tokenStore.Stats(tenantB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tokenStats.AccessTokens)
+	assert.Equal(t, int64(1), tokenStats.Expired)
+
+	// rows are not visible to the role without the tenant setting
+	var count int64
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM "+tokenTableName).Scan(&count))
+	assert.Equal(t, int64(0), count)
+
+	// rows can not be moved to other tenant
+	err = NewPGXPool(pool).InTx(ctx, func(tx pgAdapter.Adapter) error {
+		if err := SetTenantSession(ctx, tx, "tenant-a", true); err != nil {
+			return err
+		}
+		return tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET tenant_id = 'tenant-b'", tokenTableName))
+	})
+	assert.Error(t, err)
+
+	// garbage collection sees all the tenants
+	tokenStore.clean()
+	require.NoError(t, ownerPool.QueryRow(ctx, "SELECT count(*) FROM "+tokenTableName).Scan(&count))
+	assert.Equal(t, int64(2), count)
+}
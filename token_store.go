@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/go-oauth2/oauth2/v4"
-	"github.com/go-oauth2/oauth2/v4/models"
 
 	pgAdapter "github.com/vgarvardt/go-pg-adapter"
 )
@@ -17,25 +16,68 @@ import (
 // TokenStore PostgreSQL token store
 type TokenStore struct {
 	adapter   pgAdapter.Adapter
+	inTx      bool
 	tableName string
 	logger    Logger
+	tenantID  string
+	codec     Codec
+	newInfo   TokenInfoFactory
 
 	gcDisabled bool
 	gcInterval time.Duration
 	ticker     *time.Ticker
+	gcStatus   *gcStatus
 
 	initTableDisabled bool
+	rlsEnabled        bool
+
+	usageFlushInterval time.Duration
+	usage              *usageTracker
+	usageTicker        *time.Ticker
+
+	refreshIdleTimeout time.Duration
+
+	audit    auditLog
+	replicas *replicaSet
+	retry    retrier
+	limits   operationLimits
+	stats    statsSampler
+
+	statements tokenStatements
 }
 
 // TokenStoreItem data item
 type TokenStoreItem struct {
-	ID        int64     `db:"id"`
-	CreatedAt time.Time `db:"created_at"`
-	ExpiresAt time.Time `db:"expires_at"`
-	Code      string    `db:"code"`
-	Access    string    `db:"access"`
-	Refresh   string    `db:"refresh"`
-	Data      []byte    `db:"data"`
+	ID            int64      `db:"id"`
+	TenantID      string     `db:"tenant_id"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     *time.Time `db:"expires_at"`
+	Code          string     `db:"code"`
+	Access        string     `db:"access"`
+	Refresh       string     `db:"refresh"`
+	ClientID      string     `db:"client_id"`
+	UserID        string     `db:"user_id"`
+	LastUsedAt    *time.Time `db:"last_used_at"`
+	UseCount      int64      `db:"use_count"`
+	IdleExpiresAt *time.Time `db:"idle_expires_at"`
+
+	CodeExpiresAt    *time.Time `db:"code_expires_at"`
+	AccessExpiresAt  *time.Time `db:"access_expires_at"`
+	RefreshExpiresAt *time.Time `db:"refresh_expires_at"`
+
+	CodeChallenge       string `db:"code_challenge"`
+	CodeChallengeMethod string `db:"code_challenge_method"`
+	Nonce               string `db:"nonce"`
+	RedirectURI         string `db:"redirect_uri"`
+
+	Data []byte `db:"data"`
+}
+
+// tokenLookupItem is the token lookup result, lookups need the row id for usage tracking and the payload only,
+// so that nullable columns are not scanned
+type tokenLookupItem struct {
+	ID   int64  `db:"id"`
+	Data []byte `db:"data"`
 }
 
 // NewTokenStore creates PostgreSQL store instance
@@ -44,12 +86,21 @@ func NewTokenStore(adapter pgAdapter.Adapter, options ...TokenStoreOption) (*Tok
 		adapter:    adapter,
 		tableName:  "oauth2_tokens",
 		logger:     log.New(os.Stderr, "[OAUTH2-PG-ERROR]", log.LstdFlags),
+		codec:      JSONCodec{},
+		newInfo:    defaultTokenInfoFactory,
 		gcInterval: 10 * time.Minute,
+		audit:      auditLog{tableName: DefaultAuditTableName},
+		replicas:   newReplicaSet(),
+		stats:      newStatsSampler(),
 	}
 
 	for _, o := range options {
 		o(store)
 	}
+	store.replicas.logger = store.logger
+	store.retry.logger = store.logger
+	store.limits.logger = store.logger
+	store.statements = newTokenStatements(store.tableName, store.refreshIdleTimeout > 0)
 
 	var err error
 	if !store.initTableDisabled {
@@ -62,20 +113,61 @@ func NewTokenStore(adapter pgAdapter.Adapter, options ...TokenStoreOption) (*Tok
 
 	if !store.gcDisabled {
 		store.ticker = time.NewTicker(store.gcInterval)
+		store.gcStatus = newGCStatus(store.gcInterval)
 		go store.gc()
 	}
 
+	if store.usageFlushInterval > 0 {
+		store.usage = newUsageTracker()
+		store.usageTicker = time.NewTicker(store.usageFlushInterval)
+		go store.usageFlush()
+	}
+
 	return store, err
 }
 
-// Close closes the store
+// Close closes the store and writes pending token usage if usage tracking is enabled
 func (s *TokenStore) Close() error {
 	if !s.gcDisabled {
 		s.ticker.Stop()
 	}
+	if s.usageTicker != nil {
+		s.usageTicker.Stop()
+		s.flushUsage(context.Background())
+	}
 	return nil
 }
 
+// WithTx returns a copy of the store bound to the transaction, so that store operations made with the copy
+// are committed or rolled back together with the caller changes. Use NewPGXTx or NewSQLTx to get the transaction
+// adapter. The copy does not run background jobs and closing it is no-op, token usage is tracked and written
+// by the original store. Lookups made with the copy do not use read replicas, so they see the transaction changes,
+// and failed operations are not retried.
+func (s *TokenStore) WithTx(tx pgAdapter.Adapter) *TokenStore {
+	txStore := *s
+	txStore.adapter = tx
+	txStore.inTx = true
+	txStore.gcDisabled = true
+	txStore.ticker = nil
+	txStore.gcStatus = nil
+	txStore.usageTicker = nil
+	txStore.replicas = newReplicaSet()
+	// failed transaction can not be continued, so operations are not retried
+	txStore.retry.policy = nil
+	return &txStore
+}
+
+// db returns the transaction adapter bound to the context or the store adapter,
+// that sets the context tenant for row-level security policies if enabled
+func (s *TokenStore) db(ctx context.Context) pgAdapter.Adapter {
+	return rlsSession(resolveAdapter(ctx, s.adapter), s.rlsEnabled, TenantSetting, resolveTenant(ctx, s.tenantID))
+}
+
+// maintenance returns the store adapter that sees the rows of all tenants if row-level security is enabled
+func (s *TokenStore) maintenance() pgAdapter.Adapter {
+	return rlsSession(s.adapter, s.rlsEnabled, AllTenantsSetting, "on")
+}
+
 func (s *TokenStore) gc() {
 	for range s.ticker.C {
 		s.clean()
@@ -83,7 +175,10 @@ func (s *TokenStore) gc() {
 }
 
 func (s *TokenStore) initTable() error {
-	return s.adapter.Exec(context.Background(), fmt.Sprintf(`
+	ctx, done := s.limits.start(context.Background(), opMigration, "token table init")
+	defer done()
+
+	err := s.adapter.Exec(ctx, fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS %[1]s (
 	id         BIGSERIAL   NOT NULL,
 	created_at TIMESTAMPTZ NOT NULL,
@@ -91,7 +186,7 @@ CREATE TABLE IF NOT EXISTS %[1]s (
 	code       TEXT        NOT NULL,
 	access     TEXT        NOT NULL,
 	refresh    TEXT        NOT NULL,
-	data       JSONB       NOT NULL,
+	data       %[2]s       NOT NULL,
 	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
 );
 
@@ -99,85 +194,308 @@ CREATE INDEX IF NOT EXISTS idx_%[1]s_expires_at ON %[1]s (expires_at);
 CREATE INDEX IF NOT EXISTS idx_%[1]s_code ON %[1]s (code);
 CREATE INDEX IF NOT EXISTS idx_%[1]s_access ON %[1]s (access);
 CREATE INDEX IF NOT EXISTS idx_%[1]s_refresh ON %[1]s (refresh);
-`, s.tableName))
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_%[1]s_tenant_id ON %[1]s (tenant_id);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_%[1]s_user_id_client_id ON %[1]s (user_id, client_id);
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS use_count BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS idle_expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_%[1]s_idle_expires_at ON %[1]s (idle_expires_at);
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = '%[1]s'::regclass AND attname = 'access_expires_at' AND NOT attisdropped) THEN
+		ALTER TABLE %[1]s ALTER COLUMN expires_at DROP NOT NULL;
+		ALTER TABLE %[1]s ADD COLUMN code_expires_at TIMESTAMPTZ;
+		ALTER TABLE %[1]s ADD COLUMN access_expires_at TIMESTAMPTZ;
+		ALTER TABLE %[1]s ADD COLUMN refresh_expires_at TIMESTAMPTZ;
+		-- rows created before per-kind expiration tracking had the only expiration for all the credentials
+		UPDATE %[1]s SET
+			code_expires_at = CASE WHEN code <> '' THEN %[5]s END,
+			access_expires_at = CASE WHEN access <> '' THEN %[6]s END,
+			refresh_expires_at = CASE WHEN refresh <> '' THEN %[7]s END;
+	END IF;
+END $$;
+
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS code_challenge TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS code_challenge_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS redirect_uri TEXT NOT NULL DEFAULT '';
+%[4]s
+COMMENT ON TABLE %[1]s IS '%[3]s';
+`,
+		s.tableName,
+		s.codec.DataType(),
+		tokenTableSchema.comment(),
+		s.backfillColumns(),
+		s.legacyExpiresAt("Code"),
+		s.legacyExpiresAt("Access"),
+		s.legacyExpiresAt("Refresh"),
+	))
+	if err == nil && s.audit.enabled {
+		err = initAuditTable(ctx, s.adapter, s.audit.tableName)
+	}
+	if err != nil || !s.rlsEnabled {
+		return err
+	}
+
+	return EnableRowLevelSecurity(ctx, s.adapter, s.tableName)
+}
+
+// legacyExpiresAt returns the expression of the credential expiration of the row created before per-kind
+// expiration tracking. Expiration is computed from the credential creation time and lifetime of JSON payload,
+// the only row expiration is used for other codecs and payloads missing the fields.
+func (s *TokenStore) legacyExpiresAt(credential string) string {
+	if s.codec.DataType() != (JSONCodec{}).DataType() {
+		return "expires_at"
+	}
+
+	// payload keeps the lifetime in nanoseconds
+	return fmt.Sprintf(`(CASE
+				WHEN data->>'%[1]sCreateAt' IS NULL OR data->>'%[1]sExpiresIn' IS NULL THEN expires_at
+				WHEN (data->>'%[1]sExpiresIn')::bigint = 0 THEN NULL
+				ELSE (data->>'%[1]sCreateAt')::timestamptz + (data->>'%[1]sExpiresIn')::bigint / 1000 * INTERVAL '1 microsecond'
+			END)`, credential)
+}
+
+// backfillColumns returns the statement filling client and user columns of the rows created before the columns
+// were added from the payload, so that consent revocation finds them. Payloads are only readable by PostgreSQL
+// with JSONCodec, statement is empty for other codecs. Tables of schema version 2 and newer are filled already.
+func (s *TokenStore) backfillColumns() string {
+	if s.codec.DataType() != (JSONCodec{}).DataType() {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+DO $$
+BEGIN
+	IF COALESCE(substring(obj_description('%[1]s'::regclass, 'pg_class') from '%[2]s')::int, 0) < 2 THEN
+		UPDATE %[1]s SET client_id = COALESCE(data->>'ClientID', ''), user_id = COALESCE(data->>'UserID', '')
+		WHERE client_id = '' AND user_id = '';
+	END IF;
+END $$;
+`, s.tableName, schemaVersionPattern)
 }
 
 func (s *TokenStore) clean() {
-	now := time.Now()
-	err := s.adapter.Exec(context.Background(), fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.tableName), now)
+	ctx, done := s.limits.start(context.Background(), opGC, "token gc")
+	defer done()
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditTokenExpired, actor: gcActor},
+		fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1 OR idle_expires_at <= $1", s.tableName),
+		tokenAuditColumns,
+		"",
+		[]interface{}{time.Now()},
+	)
+	if err == nil {
+		err = s.maintenance().Exec(ctx, query, args...)
+	}
+	s.gcStatus.record(err)
 	if err != nil {
 		s.logger.Printf("Error while cleaning out outdated entities: %+v", err)
 	}
 }
 
-// Create creates and stores the new token information
+// Create creates and stores the new token information. Every credential, i.e. authorization code, access
+// and refresh token, gets own expiration, zero lifetime means the credential never expires.
 func (s *TokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
-	buf, err := json.Marshal(info)
+	ctx, done := s.limits.start(ctx, opWrite, "token create")
+	defer done()
+
+	item, err := s.newItem(ctx, info, time.Now())
+	if err != nil {
+		return err
+	}
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditTokenCreated, tokenHash: tokenHash(info), scope: info.GetScope()},
+		s.statements.create,
+		tokenAuditColumns,
+		"",
+		[]interface{}{
+			item.TenantID,
+			item.CreatedAt,
+			item.ExpiresAt,
+			item.Code,
+			item.Access,
+			item.Refresh,
+			item.ClientID,
+			item.UserID,
+			item.IdleExpiresAt,
+			item.CodeExpiresAt,
+			item.AccessExpiresAt,
+			item.RefreshExpiresAt,
+			item.CodeChallenge,
+			item.CodeChallengeMethod,
+			item.Nonce,
+			item.RedirectURI,
+			item.Data,
+		},
+	)
 	if err != nil {
 		return err
 	}
 
+	if err := s.retry.doWrite(ctx, "token create", func() error {
+		return s.db(ctx).Exec(ctx, query, args...)
+	}); err != nil {
+		return err
+	}
+
+	s.replicas.remember(item.Access)
+	return nil
+}
+
+// newItem returns token table row of the token information created at the given time in the store or context tenant
+func (s *TokenStore) newItem(ctx context.Context, info oauth2.TokenInfo, createdAt time.Time) (*TokenStoreItem, error) {
+	buf, err := s.codec.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
 	item := &TokenStoreItem{
+		TenantID:  resolveTenant(ctx, s.tenantID),
+		ClientID:  info.GetClientID(),
+		UserID:    info.GetUserID(),
 		Data:      buf,
-		CreatedAt: time.Now(),
+		CreatedAt: createdAt,
 	}
 
 	if code := info.GetCode(); code != "" {
 		item.Code = code
-		item.ExpiresAt = info.GetCodeCreateAt().Add(info.GetCodeExpiresIn())
-	} else {
-		item.Access = info.GetAccess()
-		item.ExpiresAt = info.GetAccessCreateAt().Add(info.GetAccessExpiresIn())
-
-		if refresh := info.GetRefresh(); refresh != "" {
-			item.Refresh = info.GetRefresh()
-			item.ExpiresAt = info.GetRefreshCreateAt().Add(info.GetRefreshExpiresIn())
-		}
+		item.CodeExpiresAt = credentialExpiresAt(info.GetCodeCreateAt(), info.GetCodeExpiresIn())
+		item.CodeChallenge = info.GetCodeChallenge()
+		item.CodeChallengeMethod = string(info.GetCodeChallengeMethod())
+		item.Nonce = codeNonce(ctx, info)
+		item.RedirectURI = info.GetRedirectURI()
 	}
 
-	return s.adapter.Exec(
-		ctx,
-		fmt.Sprintf("INSERT INTO %s (created_at, expires_at, code, access, refresh, data) VALUES ($1, $2, $3, $4, $5, $6)", s.tableName),
-		item.CreatedAt,
-		item.ExpiresAt,
-		item.Code,
-		item.Access,
-		item.Refresh,
-		item.Data,
-	)
+	if access := info.GetAccess(); access != "" {
+		item.Access = access
+		item.AccessExpiresAt = credentialExpiresAt(info.GetAccessCreateAt(), info.GetAccessExpiresIn())
+	}
+
+	if refresh := info.GetRefresh(); refresh != "" {
+		item.Refresh = refresh
+		item.RefreshExpiresAt = credentialExpiresAt(info.GetRefreshCreateAt(), info.GetRefreshExpiresIn())
+		item.IdleExpiresAt = s.refreshIdleExpiresAt(item.CreatedAt, item.RefreshExpiresAt)
+	}
+
+	item.ExpiresAt = rowExpiresAt(item)
+
+	return item, nil
 }
 
-// RemoveByCode deletes the authorization code
-func (s *TokenStore) RemoveByCode(ctx context.Context, code string) error {
-	err := s.adapter.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE code = $1", s.tableName), code)
-	if err == pgAdapter.ErrNoRows {
+func (s *TokenStore) refreshIdleExpiresAt(now time.Time, refreshExpiresAt *time.Time) *time.Time {
+	if s.refreshIdleTimeout <= 0 {
 		return nil
 	}
-	return err
+
+	idleExpiresAt := now.Add(s.refreshIdleTimeout)
+	if refreshExpiresAt != nil && idleExpiresAt.After(*refreshExpiresAt) {
+		idleExpiresAt = *refreshExpiresAt
+	}
+	return &idleExpiresAt
 }
 
-// RemoveByAccess uses the access token to delete the token information
-func (s *TokenStore) RemoveByAccess(ctx context.Context, access string) error {
-	err := s.adapter.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE access = $1", s.tableName), access)
-	if err == pgAdapter.ErrNoRows {
+// credentialExpiresAt returns authorization code, access or refresh token expiration, nil for zero lifetime
+// that means the credential never expires, the same way oauth2 manager treats token lifetime
+func credentialExpiresAt(createAt time.Time, expiresIn time.Duration) *time.Time {
+	if expiresIn == 0 {
 		return nil
 	}
-	return err
+
+	expiresAt := createAt.Add(expiresIn)
+	return &expiresAt
+}
+
+// rowExpiresAt returns the time all the row credentials are expired at, nil if any of them never expires
+func rowExpiresAt(item *TokenStoreItem) *time.Time {
+	var expiresAt *time.Time
+	for _, c := range []struct {
+		value     string
+		expiresAt *time.Time
+	}{
+		{item.Code, item.CodeExpiresAt},
+		{item.Access, item.AccessExpiresAt},
+		{item.Refresh, item.RefreshExpiresAt},
+	} {
+		if c.value == "" {
+			continue
+		}
+		if c.expiresAt == nil {
+			return nil
+		}
+		if expiresAt == nil || c.expiresAt.After(*expiresAt) {
+			expiresAt = c.expiresAt
+		}
+	}
+	return expiresAt
+}
+
+// RemoveByCode deletes the authorization code
+func (s *TokenStore) RemoveByCode(ctx context.Context, code string) error {
+	return s.remove(ctx, "code", code)
+}
+
+// RemoveByAccess uses the access token to delete the token information
+func (s *TokenStore) RemoveByAccess(ctx context.Context, access string) error {
+	return s.remove(ctx, "access", access)
 }
 
 // RemoveByRefresh uses the refresh token to delete the token information
 func (s *TokenStore) RemoveByRefresh(ctx context.Context, refresh string) error {
-	err := s.adapter.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE refresh = $1", s.tableName), refresh)
-	if err == pgAdapter.ErrNoRows {
-		return nil
+	return s.remove(ctx, "refresh", refresh)
+}
+
+// remove deletes the token information by the credential column value
+func (s *TokenStore) remove(ctx context.Context, column, value string) error {
+	ctx, done := s.limits.start(ctx, opWrite, "token remove")
+	defer done()
+
+	query, args, err := s.audit.wrap(
+		ctx,
+		auditEntry{event: AuditTokenRemoved, tokenHash: hashSecret(value)},
+		s.statements.remove[column],
+		tokenAuditColumns+", access",
+		`SELECT COALESCE(json_agg(access) FILTER (WHERE access <> ''), '[]')::text AS access FROM changed`,
+		[]interface{}{value, resolveTenant(ctx, s.tenantID)},
+	)
+	if err != nil {
+		return err
+	}
+
+	var removed struct {
+		Access string `db:"access"`
+	}
+	if err := s.retry.do(ctx, "token remove", func() error {
+		return s.db(ctx).SelectOne(ctx, &removed, query, args...)
+	}); err != nil {
+		return err
+	}
+
+	var access []string
+	if err := json.Unmarshal([]byte(removed.Access), &access); err != nil {
+		return err
 	}
-	return err
+	// lagging replicas may still have removed tokens, including the ones removed by code or refresh
+	s.replicas.remember(access...)
+	return nil
 }
 
 func (s *TokenStore) toTokenInfo(data []byte) (oauth2.TokenInfo, error) {
-	var tm models.Token
-	err := json.Unmarshal(data, &tm)
-	return &tm, err
+	info := s.newInfo()
+	err := s.codec.Unmarshal(data, info)
+	return info, err
 }
 
 // GetByCode uses the authorization code for token information data
@@ -186,25 +504,37 @@ func (s *TokenStore) GetByCode(ctx context.Context, code string) (oauth2.TokenIn
 		return nil, nil
 	}
 
-	var item TokenStoreItem
-	if err := s.adapter.SelectOne(ctx, &item, fmt.Sprintf("SELECT * FROM %s WHERE code = $1", s.tableName), code); err != nil {
+	ctx, done := s.limits.start(ctx, opRead, "token lookup")
+	defer done()
+
+	var item tokenLookupItem
+	if err := s.retry.do(ctx, "token lookup", func() error {
+		return s.statements.getByCode.selectOne(ctx, s.db(ctx), &item, code, resolveTenant(ctx, s.tenantID), time.Now())
+	}); err != nil {
 		return nil, err
 	}
 
 	return s.toTokenInfo(item.Data)
 }
 
-// GetByAccess uses the access token for token information data
+// GetByAccess uses the access token for token information data, lookup runs on a replica if any configured
 func (s *TokenStore) GetByAccess(ctx context.Context, access string) (oauth2.TokenInfo, error) {
 	if access == "" {
 		return nil, nil
 	}
 
-	var item TokenStoreItem
-	if err := s.adapter.SelectOne(ctx, &item, fmt.Sprintf("SELECT * FROM %s WHERE access = $1", s.tableName), access); err != nil {
+	ctx, done := s.limits.start(ctx, opRead, "token lookup")
+	defer done()
+
+	var item tokenLookupItem
+	if err := s.retry.do(ctx, "token lookup", func() error {
+		return s.replicas.selectOne(ctx, s.db(ctx), access, &item, s.statements.getByAccess, access, resolveTenant(ctx, s.tenantID), time.Now())
+	}); err != nil {
 		return nil, err
 	}
 
+	s.trackUsage(item.ID)
+
 	return s.toTokenInfo(item.Data)
 }
 
@@ -214,10 +544,25 @@ func (s *TokenStore) GetByRefresh(ctx context.Context, refresh string) (oauth2.T
 		return nil, nil
 	}
 
-	var item TokenStoreItem
-	if err := s.adapter.SelectOne(ctx, &item, fmt.Sprintf("SELECT * FROM %s WHERE refresh = $1", s.tableName), refresh); err != nil {
+	ctx, done := s.limits.start(ctx, opRead, "token lookup")
+	defer done()
+
+	now := time.Now()
+	args := []interface{}{refresh, resolveTenant(ctx, s.tenantID), now}
+	if s.refreshIdleTimeout > 0 {
+		// statement extends inactivity deadline up to the given time
+		args = append(args, now.Add(s.refreshIdleTimeout))
+	}
+
+	var item tokenLookupItem
+	// idle deadline extension sets the same value on repeat, so it is safe to retry as well
+	if err := s.retry.do(ctx, "token lookup", func() error {
+		return s.statements.getByRefresh.selectOne(ctx, s.db(ctx), &item, args...)
+	}); err != nil {
 		return nil, err
 	}
 
+	s.trackUsage(item.ID)
+
 	return s.toTokenInfo(item.Data)
 }
@@ -0,0 +1,211 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// createBatchSize is the maximum number of tokens inserted with a single statement
+const createBatchSize = 1000
+
+// BatchError is the error of the batch operation, Errors are the errors of the batch items by their index,
+// nil for the succeeded items
+type BatchError struct {
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	var failed int
+	var first error
+	for _, err := range e.Errors {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d batch items failed, first error: %s", failed, len(e.Errors), first)
+}
+
+type batchTokenItem struct {
+	TenantID         string     `json:"tenant_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	Code             string     `json:"code"`
+	Access           string     `json:"access"`
+	Refresh          string     `json:"refresh"`
+	ClientID         string     `json:"client_id"`
+	UserID           string     `json:"user_id"`
+	IdleExpiresAt    *time.Time `json:"idle_expires_at"`
+	CodeExpiresAt    *time.Time `json:"code_expires_at"`
+	AccessExpiresAt  *time.Time `json:"access_expires_at"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at"`
+
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Nonce               string `json:"nonce"`
+	RedirectURI         string `json:"redirect_uri"`
+
+	Data      []byte `json:"data"`
+	TokenHash string `json:"token_hash"`
+	Scope     string `json:"scope"`
+}
+
+// dataImport returns the expression decoding base64 encoded data column value
+func dataImport(codec Codec, column string) string {
+	if codec.DataType() == "BYTEA" {
+		return fmt.Sprintf("decode(%s, 'base64')", column)
+	}
+	return fmt.Sprintf("convert_from(decode(%s, 'base64'), 'UTF8')::jsonb", column)
+}
+
+// CreateBatch creates and stores many token information items with a single statement per up to 1000 items,
+// i.e. in a single round trip for the most batches. Returns *BatchError with the errors of the items that were
+// not created. Statement is atomic, so the items of the statement failed with not transient error are inserted
+// one by one to report the errors of the failing items only. Failed statement aborts the transaction the store
+// is bound to, all its items fail with the statement error then.
+func (s *TokenStore) CreateBatch(ctx context.Context, infos []oauth2.TokenInfo) error {
+	ctx, done := s.limits.start(ctx, opWrite, "token batch create")
+	defer done()
+
+	errs := make([]error, len(infos))
+	failed := false
+	now := time.Now()
+
+	for start := 0; start < len(infos); start += createBatchSize {
+		end := start + createBatchSize
+		if end > len(infos) {
+			end = len(infos)
+		}
+
+		indexes := make([]int, 0, end-start)
+		items := make([]*batchTokenItem, 0, end-start)
+		for i := start; i < end; i++ {
+			item, err := s.newItem(ctx, infos[i], now)
+			if err != nil {
+				errs[i], failed = err, true
+				continue
+			}
+
+			indexes = append(indexes, i)
+			items = append(items, &batchTokenItem{
+				TenantID:         item.TenantID,
+				CreatedAt:        item.CreatedAt,
+				ExpiresAt:        item.ExpiresAt,
+				Code:             item.Code,
+				Access:           item.Access,
+				Refresh:          item.Refresh,
+				ClientID:         item.ClientID,
+				UserID:           item.UserID,
+				IdleExpiresAt:    item.IdleExpiresAt,
+				CodeExpiresAt:    item.CodeExpiresAt,
+				AccessExpiresAt:  item.AccessExpiresAt,
+				RefreshExpiresAt: item.RefreshExpiresAt,
+
+				CodeChallenge:       item.CodeChallenge,
+				CodeChallengeMethod: item.CodeChallengeMethod,
+				Nonce:               item.Nonce,
+				RedirectURI:         item.RedirectURI,
+
+				Data:      item.Data,
+				TokenHash: tokenHash(infos[i]),
+				Scope:     infos[i].GetScope(),
+			})
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		err := s.insertBatch(ctx, items)
+		if err == nil {
+			access := make([]string, 0, len(items))
+			for _, item := range items {
+				access = append(access, item.Access)
+			}
+			s.replicas.remember(access...)
+			continue
+		}
+
+		if !s.itemsRetryable(ctx, items, err) {
+			for _, i := range indexes {
+				errs[i] = err
+			}
+			failed = true
+			continue
+		}
+
+		// single failing item, e.g. violating a constraint, fails the whole statement,
+		// so the items are inserted one by one to find out which ones are failing
+		for j, item := range items {
+			if err := s.insertBatch(ctx, []*batchTokenItem{item}); err != nil {
+				errs[indexes[j]], failed = err, true
+				continue
+			}
+			s.replicas.remember(item.Access)
+		}
+	}
+
+	if failed {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// itemsRetryable checks if the items of the failed statement can be inserted one by one: statement was rolled back
+// for sure, i.e. it did not fail with transient error, and the failure did not abort the transaction
+func (s *TokenStore) itemsRetryable(ctx context.Context, items []*batchTokenItem, err error) bool {
+	if _, ok := TransactionFromContext(ctx); ok || s.inTx {
+		return false
+	}
+	return len(items) > 1 && ctx.Err() == nil && !IsRetryable(err)
+}
+
+func (s *TokenStore) insertBatch(ctx context.Context, items []*batchTokenItem) error {
+	buf, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	columns := "tenant_id, created_at, expires_at, code, access, refresh, client_id, user_id, idle_expires_at, code_expires_at, access_expires_at, refresh_expires_at, code_challenge, code_challenge_method, nonce, redirect_uri"
+	recordset := `json_to_recordset($1::json) AS i(
+	tenant_id TEXT, created_at TIMESTAMPTZ, expires_at TIMESTAMPTZ, code TEXT, access TEXT, refresh TEXT, client_id TEXT, user_id TEXT,
+	idle_expires_at TIMESTAMPTZ, code_expires_at TIMESTAMPTZ, access_expires_at TIMESTAMPTZ, refresh_expires_at TIMESTAMPTZ,
+	code_challenge TEXT, code_challenge_method TEXT, nonce TEXT, redirect_uri TEXT, data TEXT, token_hash TEXT, scope TEXT
+)`
+
+	query := fmt.Sprintf("INSERT INTO %s (%s, data) SELECT %s, %s FROM %s", s.tableName, columns, columns, dataImport(s.codec, "data"), recordset)
+	args := []interface{}{string(buf)}
+
+	if s.audit.enabled {
+		// token ids are allocated upfront, so that audit records are written from the same input rows
+		// along with the token hash and scope that are not stored in the token table
+		var audit string
+		audit, args, err = s.audit.insert(
+			ctx,
+			auditEntry{event: AuditTokenCreated, rowTokenHash: true},
+			"(SELECT tenant_id, client_id, user_id, id AS token_id, token_hash, scope FROM input) AS input_audit",
+			args,
+		)
+		if err != nil {
+			return err
+		}
+
+		query = fmt.Sprintf(`
+WITH input AS (
+	SELECT nextval(pg_get_serial_sequence('%[1]s', 'id')) AS id, i.* FROM %[4]s
+), created AS (
+	INSERT INTO %[1]s (id, %[2]s, data) SELECT id, %[2]s, %[3]s FROM input
+)
+%[5]s`, s.tableName, columns, dataImport(s.codec, "data"), recordset, audit)
+	}
+
+	return s.retry.doWrite(ctx, "token batch create", func() error {
+		return s.db(ctx).Exec(ctx, strings.TrimSpace(query), args...)
+	})
+}
@@ -0,0 +1,177 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenToken is the token information that can not be encoded
+type brokenToken struct {
+	models.Token
+	C chan int
+}
+
+func newBatchTokens(n int) []oauth2.TokenInfo {
+	infos := make([]oauth2.TokenInfo, 0, n)
+	for i := 0; i < n; i++ {
+		token := models.NewToken()
+		token.SetClientID("client")
+		token.SetUserID(fmt.Sprintf("user %d", i))
+		token.SetScope("read")
+		token.SetAccess(fmt.Sprintf("access %d %s", i, time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Hour)
+		infos = append(infos, token)
+	}
+	return infos
+}
+
+func TestTokenStore_CreateBatch_errors(t *testing.T) {
+	adapter := new(mockAdapter)
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	statementErr := errors.New("statement failed")
+	itemErr := errors.New("item failed")
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(statementErr).Once()
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(itemErr).Once()
+
+	infos := newBatchTokens(createBatchSize + 2)
+	infos[1] = &brokenToken{}
+
+	err = store.CreateBatch(context.Background(), infos)
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Errors, len(infos))
+
+	// items are inserted with a statement per chunk, items of the failed chunk are inserted one by one
+	adapter.AssertNumberOfCalls(t, "Exec", 4)
+
+	assert.NoError(t, batchErr.Errors[0])
+	assert.Error(t, batchErr.Errors[1])
+	assert.NotEqual(t, statementErr, batchErr.Errors[1])
+	assert.NoError(t, batchErr.Errors[createBatchSize-1])
+	assert.NoError(t, batchErr.Errors[createBatchSize])
+	assert.Equal(t, itemErr, batchErr.Errors[createBatchSize+1])
+	assert.Contains(t, batchErr.Error(), "2 of 1002 batch items failed")
+
+	// failed statement aborts the transaction, so the items are not inserted one by one within it
+	tx := new(mockAdapter)
+	tx.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(statementErr).Once()
+
+	err = store.WithTx(tx).CreateBatch(context.Background(), newBatchTokens(2))
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []error{statementErr, statementErr}, batchErr.Errors)
+	tx.AssertExpectations(t)
+}
+
+func TestTokenStore_CreateBatch(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := NewPGXPool(pool)
+	auditTableName := fmt.Sprintf("audit_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		t.Run(codec.DataType(), func(t *testing.T) {
+			store, err := NewTokenStore(
+				adapter,
+				WithTokenStoreTableName(generateTokenTableName()),
+				WithTokenStoreGCDisabled(),
+				WithTokenStoreCodec(codec, defaultTokenInfoFactory),
+				WithTokenStoreAudit(),
+				WithTokenStoreAuditTableName(auditTableName),
+			)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, store.Close())
+			}()
+
+			infos := newBatchTokens(3)
+			require.NoError(t, store.CreateBatch(ctx, infos))
+
+			for _, info := range infos {
+				stored, err := store.GetByAccess(ctx, info.GetAccess())
+				require.NoError(t, err)
+				assert.Equal(t, info.GetUserID(), stored.GetUserID())
+
+				records, err := auditStoreFor(t, adapter, auditTableName).Query(ctx, AuditQuery{UserID: info.GetUserID()})
+				require.NoError(t, err)
+				require.NotEmpty(t, records)
+
+				record := records[len(records)-1]
+				assert.Equal(t, AuditTokenCreated, record.Event)
+				assert.Equal(t, hashSecret(info.GetAccess()), record.TokenHash)
+				assert.Equal(t, "read", record.Scope)
+				assert.NotNil(t, record.TokenID)
+			}
+		})
+	}
+}
+
+func auditStoreFor(t *testing.T, adapter *PGXPool, tableName string) *AuditStore {
+	store, err := NewAuditStore(adapter, WithAuditStoreTableName(tableName), WithAuditStoreInitTableDisabled())
+	require.NoError(t, err)
+	return store
+}
+
+func benchmarkTokenStore(b *testing.B) (*TokenStore, func()) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(b, err)
+
+	store, err := NewTokenStore(NewPGXPool(pool), WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(b, err)
+
+	return store, func() {
+		assert.NoError(b, store.Close())
+		pool.Close()
+	}
+}
+
+func BenchmarkTokenStore_Create(b *testing.B) {
+	store, closeStore := benchmarkTokenStore(b)
+	defer closeStore()
+
+	ctx := context.Background()
+	infos := newBatchTokens(b.N)
+	b.ResetTimer()
+
+	for _, info := range infos {
+		if err := store.Create(ctx, info); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenStore_CreateBatch(b *testing.B) {
+	store, closeStore := benchmarkTokenStore(b)
+	defer closeStore()
+
+	ctx := context.Background()
+	infos := newBatchTokens(b.N)
+	b.ResetTimer()
+
+	for start := 0; start < len(infos); start += createBatchSize {
+		end := start + createBatchSize
+		if end > len(infos) {
+			end = len(infos)
+		}
+		if err := store.CreateBatch(ctx, infos[start:end]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
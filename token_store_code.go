@@ -0,0 +1,146 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	oauth2Errors "github.com/go-oauth2/oauth2/v4/errors"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// TokenNonce is the token information carrying OpenID Connect nonce of the authorization request,
+// token store keeps the nonce of the authorization code in the dedicated column
+type TokenNonce interface {
+	GetNonce() string
+}
+
+type nonceCtxKey struct{}
+
+// WithNonce returns a copy of the context that makes the token store keep OpenID Connect nonce of the authorization
+// request with the authorization code created with the context. Nonce from the context takes precedence
+// over the one of TokenNonce implementations.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceCtxKey{}, nonce)
+}
+
+// NonceFromContext returns the nonce stored in the context, if any
+func NonceFromContext(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(nonceCtxKey{}).(string)
+	return nonce, ok
+}
+
+func codeNonce(ctx context.Context, info oauth2.TokenInfo) string {
+	if nonce, ok := NonceFromContext(ctx); ok {
+		return nonce
+	}
+	if n, ok := info.(TokenNonce); ok {
+		return n.GetNonce()
+	}
+	return ""
+}
+
+// CodeExchange is the authorization code consumed by the code exchange
+type CodeExchange struct {
+	oauth2.TokenInfo
+
+	// Nonce is OpenID Connect nonce of the authorization request, empty if there was none
+	Nonce string
+}
+
+type codeExchangeItem struct {
+	ClientOK      bool   `db:"client_ok"`
+	RedirectURIOK bool   `db:"redirect_uri_ok"`
+	VerifierOK    bool   `db:"verifier_ok"`
+	Nonce         string `db:"nonce"`
+	Data          []byte `db:"data"`
+}
+
+// VerifyCodeExchange consumes the authorization code exchanged for the tokens. Code is removed only if it was
+// issued to the client, redirect URI matches the one of the authorization request, if there was any, and PKCE
+// code verifier matches the code challenge, if there was any. Verification and removal are made with a single
+// statement, so that the same code can not be exchanged twice concurrently.
+// Returns oauth2 errors package errors: ErrInvalidAuthorizeCode for unknown, expired, already consumed code
+// or the code of another client, ErrInvalidRedirectURI, ErrMissingCodeVerifier and ErrInvalidCodeChallenge
+// for the mismatches. Code is not removed when verification fails.
+func (s *TokenStore) VerifyCodeExchange(ctx context.Context, code, verifier, redirectURI, clientID string) (*CodeExchange, error) {
+	if code == "" {
+		return nil, oauth2Errors.ErrInvalidAuthorizeCode
+	}
+
+	ctx, done := s.limits.start(ctx, opWrite, "token code exchange")
+	defer done()
+
+	s256 := sha256.Sum256([]byte(verifier))
+	args := []interface{}{
+		code,
+		resolveTenant(ctx, s.tenantID),
+		time.Now(),
+		clientID,
+		redirectURI,
+		verifier,
+		base64.RawURLEncoding.EncodeToString(s256[:]),
+	}
+
+	// code row is locked, so that concurrent exchange waits for this one and does not find the code afterwards
+	query := fmt.Sprintf(`
+WITH found AS (
+	SELECT id, nonce,
+		client_id = $4 AS client_ok,
+		(redirect_uri = '' OR redirect_uri = $5) AS redirect_uri_ok,
+		CASE
+			WHEN code_challenge = '' THEN $6 = ''
+			WHEN code_challenge_method = '%[2]s' THEN rtrim(code_challenge, '=') = $7
+			ELSE code_challenge = $6
+		END AS verifier_ok
+	FROM %[1]s
+	WHERE code = $1 AND tenant_id = $2 AND (code_expires_at IS NULL OR code_expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3)
+	FOR UPDATE
+), changed AS (
+	DELETE FROM %[1]s WHERE id IN (SELECT id FROM found WHERE client_ok AND redirect_uri_ok AND verifier_ok)
+	RETURNING %[3]s, data
+)`, s.tableName, oauth2.CodeChallengeS256, tokenAuditColumns)
+
+	if s.audit.enabled {
+		audit, auditArgs, err := s.audit.insert(ctx, auditEntry{event: AuditTokenRemoved, tokenHash: hashSecret(code)}, "changed", args)
+		if err != nil {
+			return nil, err
+		}
+		query += fmt.Sprintf(", audited AS (%s)", audit)
+		args = auditArgs
+	}
+	query += "\nSELECT f.client_ok, f.redirect_uri_ok, f.verifier_ok, f.nonce, c.data FROM found f LEFT JOIN changed c ON c.token_id = f.id"
+
+	var item codeExchangeItem
+	// statement failed with connection error could have removed the code, so that the retry would reject valid exchange
+	err := s.retry.doWrite(ctx, "token code exchange", func() error {
+		return s.db(ctx).SelectOne(ctx, &item, query, args...)
+	})
+	if err == pgAdapter.ErrNoRows {
+		return nil, oauth2Errors.ErrInvalidAuthorizeCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !item.ClientOK:
+		return nil, oauth2Errors.ErrInvalidAuthorizeCode
+	case !item.RedirectURIOK:
+		return nil, oauth2Errors.ErrInvalidRedirectURI
+	case !item.VerifierOK && verifier == "":
+		return nil, oauth2Errors.ErrMissingCodeVerifier
+	case !item.VerifierOK:
+		return nil, oauth2Errors.ErrInvalidCodeChallenge
+	}
+
+	info, err := s.toTokenInfo(item.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &CodeExchange{TokenInfo: info, Nonce: item.Nonce}, nil
+}
@@ -0,0 +1,171 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	oauth2Errors "github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nonceToken struct {
+	models.Token
+	Nonce string
+}
+
+func (t *nonceToken) GetNonce() string {
+	return t.Nonce
+}
+
+func newCodeToken(challenge string, method oauth2.CodeChallengeMethod) *models.Token {
+	token := models.NewToken()
+	token.SetClientID("client")
+	token.SetUserID("user")
+	token.SetRedirectURI("https://example.com/callback")
+	token.SetCode(fmt.Sprintf("code %s", time.Now().String()))
+	token.SetCodeCreateAt(time.Now())
+	token.SetCodeExpiresIn(time.Minute)
+	token.SetCodeChallenge(challenge)
+	token.SetCodeChallengeMethod(method)
+	return token
+}
+
+func TestTokenStore_newItem_code(t *testing.T) {
+	store, err := NewTokenStore(new(mockAdapter), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	code := &nonceToken{Token: *newCodeToken("challenge", oauth2.CodeChallengeS256), Nonce: "token nonce"}
+
+	item, err := store.newItem(ctx, code, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "challenge", item.CodeChallenge)
+	assert.Equal(t, "S256", item.CodeChallengeMethod)
+	assert.Equal(t, "token nonce", item.Nonce)
+	assert.Equal(t, "https://example.com/callback", item.RedirectURI)
+
+	// nonce from the context takes precedence
+	item, err = store.newItem(WithNonce(ctx, "context nonce"), code, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "context nonce", item.Nonce)
+
+	// authorization request data is kept for the codes only
+	access := newBatchTokens(1)[0]
+	access.SetRedirectURI("https://example.com/callback")
+	item, err = store.newItem(WithNonce(ctx, "context nonce"), access, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, item.Nonce)
+	assert.Empty(t, item.RedirectURI)
+}
+
+func TestTokenStore_VerifyCodeExchange(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := NewPGXPool(pool)
+	tableName := generateTokenTableName()
+	store, err := NewTokenStore(
+		adapter,
+		WithTokenStoreTableName(tableName),
+		WithTokenStoreGCDisabled(),
+		WithTokenStoreAudit(),
+		WithTokenStoreAuditTableName(tableName+"_audit"),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	ctx := context.Background()
+	verifier := "dBjftJeZ4CVP-mJ92K9WwqYV8pnCYoHHwGybvEXajGA"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	redirectURI := "https://example.com/callback"
+
+	code := newCodeToken(challenge, oauth2.CodeChallengeS256)
+	require.NoError(t, store.Create(WithNonce(ctx, "nonce"), code))
+
+	for name, tc := range map[string]struct {
+		verifier, redirectURI, clientID string
+		err                             error
+	}{
+		"another client":   {verifier, redirectURI, "another", oauth2Errors.ErrInvalidAuthorizeCode},
+		"another redirect": {verifier, "https://example.com/another", "client", oauth2Errors.ErrInvalidRedirectURI},
+		"missing verifier": {"", redirectURI, "client", oauth2Errors.ErrMissingCodeVerifier},
+		"invalid verifier": {challenge, redirectURI, "client", oauth2Errors.ErrInvalidCodeChallenge},
+	} {
+		_, err := store.VerifyCodeExchange(ctx, code.GetCode(), tc.verifier, tc.redirectURI, tc.clientID)
+		assert.Equal(t, tc.err, err, name)
+	}
+
+	// failed verifications kept the code
+	exchange, err := store.VerifyCodeExchange(ctx, code.GetCode(), verifier, redirectURI, "client")
+	require.NoError(t, err)
+	assert.Equal(t, "nonce", exchange.Nonce)
+	assert.Equal(t, "user", exchange.GetUserID())
+
+	// code is consumed
+	_, err = store.VerifyCodeExchange(ctx, code.GetCode(), verifier, redirectURI, "client")
+	assert.Equal(t, oauth2Errors.ErrInvalidAuthorizeCode, err)
+
+	records, err := auditStoreFor(t, adapter, tableName+"_audit").Query(ctx, AuditQuery{ClientID: "client"})
+	require.NoError(t, err)
+	var removed int
+	for _, record := range records {
+		if record.Event == AuditTokenRemoved {
+			removed++
+			assert.Equal(t, hashSecret(code.GetCode()), record.TokenHash)
+		}
+	}
+	assert.Equal(t, 1, removed)
+
+	// plain challenge and the code without challenge
+	plain := newCodeToken("plain verifier", oauth2.CodeChallengePlain)
+	require.NoError(t, store.Create(ctx, plain))
+	_, err = store.VerifyCodeExchange(ctx, plain.GetCode(), "plain verifier", redirectURI, "client")
+	assert.NoError(t, err)
+
+	noPKCE := newCodeToken("", "")
+	require.NoError(t, store.Create(ctx, noPKCE))
+	_, err = store.VerifyCodeExchange(ctx, noPKCE.GetCode(), "unexpected", redirectURI, "client")
+	assert.Equal(t, oauth2Errors.ErrInvalidCodeChallenge, err)
+	_, err = store.VerifyCodeExchange(ctx, noPKCE.GetCode(), "", redirectURI, "client")
+	assert.NoError(t, err)
+
+	// concurrent exchanges of the same code - only one succeeds
+	concurrent := newCodeToken(challenge, oauth2.CodeChallengeS256)
+	require.NoError(t, store.Create(ctx, concurrent))
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.VerifyCodeExchange(ctx, concurrent.GetCode(), verifier, redirectURI, "client")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var succeeded int
+	for err := range results {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.Equal(t, oauth2Errors.ErrInvalidAuthorizeCode, err)
+	}
+	assert.Equal(t, 1, succeeded)
+}
@@ -1,6 +1,10 @@
 package pg
 
-import "time"
+import (
+	"time"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
 
 // TokenStoreOption is the configuration options type for token store
 type TokenStoreOption func(s *TokenStore)
@@ -39,3 +43,115 @@ func WithTokenStoreInitTableDisabled() TokenStoreOption {
 		s.initTableDisabled = true
 	}
 }
+
+// WithTokenStoreTenantID returns option that scopes token store to the tenant,
+// tenant set to the operation context with WithTenant takes precedence
+func WithTokenStoreTenantID(tenantID string) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithTokenStoreRowLevelSecurity returns option that enables tenant row-level security policy on table creation,
+// see EnableRowLevelSecurity for details
+func WithTokenStoreRowLevelSecurity() TokenStoreOption {
+	return func(s *TokenStore) {
+		s.rlsEnabled = true
+	}
+}
+
+// WithTokenStoreCodec returns option that sets token store payload codec and the factory of the token information
+// type payloads are decoded into, nil factory keeps the default models.Token type.
+// Codec data type is used on table creation only, so changing codec for the existing table requires migration.
+func WithTokenStoreCodec(codec Codec, factory TokenInfoFactory) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.codec = codec
+		if factory != nil {
+			s.newInfo = factory
+		}
+	}
+}
+
+// WithTokenStoreUsageTracking returns option that enables tracking of last usage time and usage count
+// of access and refresh tokens. Usage is accumulated in memory and written once per flush interval
+// and on store closing, so that token lookups do not result in writes.
+func WithTokenStoreUsageTracking(flushInterval time.Duration) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.usageFlushInterval = flushInterval
+	}
+}
+
+// WithTokenStoreRefreshIdleTimeout returns option that sets refresh token inactivity timeout. Refresh token
+// that was not used for the timeout duration is treated as expired, every refresh token lookup extends
+// the inactivity deadline, but never past the token absolute expiration.
+func WithTokenStoreRefreshIdleTimeout(idleTimeout time.Duration) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.refreshIdleTimeout = idleTimeout
+	}
+}
+
+// WithTokenStoreAudit returns option that enables audit log of token creation and removal,
+// audit records are written by the same statements as the changes
+func WithTokenStoreAudit() TokenStoreOption {
+	return func(s *TokenStore) {
+		s.audit.enabled = true
+	}
+}
+
+// WithTokenStoreAuditTableName returns option that sets audit log table name
+func WithTokenStoreAuditTableName(tableName string) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.audit.tableName = tableName
+	}
+}
+
+// WithTokenStoreReplicas returns option that routes access token lookups to the read replicas in round-robin manner,
+// store adapter is the primary used for all the other operations
+func WithTokenStoreReplicas(replicas ...pgAdapter.Adapter) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.replicas.adapters = replicas
+	}
+}
+
+// WithTokenStoreReadYourWritesWindow returns option that sets the time access token lookups fall back to the primary
+// when replica does not have the token created by the store yet, default is DefaultReadYourWritesWindow.
+// Only the tokens created by the same store instance are tracked.
+func WithTokenStoreReadYourWritesWindow(window time.Duration) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.replicas.window = window
+	}
+}
+
+// WithTokenStoreRetryPolicy returns option that enables retries of token lookups and removals failed with
+// transient PostgreSQL errors, see IsRetryable, and of token creation failed with serialization failure or deadlock.
+// Every retry is logged with the store logger.
+func WithTokenStoreRetryPolicy(policy RetryPolicy) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.retry.policy = &policy
+	}
+}
+
+// WithTokenStoreTimeouts returns option that sets token store operations default timeouts,
+// applied only when the operation context has no deadline
+func WithTokenStoreTimeouts(timeouts OperationTimeouts) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.limits.timeouts = timeouts
+	}
+}
+
+// WithTokenStoreSlowQueryThreshold returns option that enables logging of the token store operations
+// that took at least threshold time
+func WithTokenStoreSlowQueryThreshold(threshold time.Duration) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.limits.slowThreshold = threshold
+	}
+}
+
+// WithTokenStoreStatsLimits returns option that sets the number of clients and users in the statistics top lists
+// and the table rows estimate up to which statistics are counted exactly, bigger tables are sampled.
+// Zero exact count limit disables sampling.
+func WithTokenStoreStatsLimits(topLimit int, exactCountLimit int64) TokenStoreOption {
+	return func(s *TokenStore) {
+		s.stats = statsSampler{topLimit: topLimit, exactCountLimit: exactCountLimit}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-oauth2/oauth2/v4/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
 )
 
 func TestWithTokenStoreGCDisabled(t *testing.T) {
@@ -57,3 +60,87 @@ func TestWithTokenStoreLogger(t *testing.T) {
 	assert.Equal(t, 12, l.args[1][0])
 	assert.Equal(t, "22", l.args[1][1])
 }
+
+func TestWithTokenStoreTenantID(t *testing.T) {
+	randomTenant := time.Now().String()
+
+	store, err := NewTokenStore(nil, WithTokenStoreTenantID(randomTenant), WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, randomTenant, store.tenantID)
+}
+
+func TestWithTokenStoreRowLevelSecurity(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreRowLevelSecurity(), WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.True(t, store.rlsEnabled)
+}
+
+func TestWithTokenStoreCodec(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, JSONCodec{}, store.codec)
+	assert.IsType(t, &models.Token{}, store.newInfo())
+
+	store, err = NewTokenStore(nil, WithTokenStoreCodec(GobCodec{}, newTenantToken), WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, GobCodec{}, store.codec)
+	assert.IsType(t, &tenantToken{}, store.newInfo())
+}
+
+func TestWithTokenStoreUsageTracking(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreUsageTracking(time.Minute), WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, store.usageFlushInterval)
+	assert.NotNil(t, store.usage)
+	assert.NotNil(t, store.usageTicker)
+	store.usageTicker.Stop()
+}
+
+func TestWithTokenStoreRefreshIdleTimeout(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreRefreshIdleTimeout(time.Hour), WithTokenStoreGCDisabled(), WithTokenStoreInitTableDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, store.refreshIdleTimeout)
+
+	now := time.Now()
+	assert.Equal(t, now.Add(time.Hour), *store.refreshIdleExpiresAt(now, nil))
+	assert.Equal(t, now.Add(time.Minute), *store.refreshIdleExpiresAt(now, credentialExpiresAt(now, time.Minute)))
+}
+
+func TestWithTokenStoreReplicas(t *testing.T) {
+	replica := new(mockAdapter)
+
+	store, err := NewTokenStore(nil, WithTokenStoreReplicas(replica), WithTokenStoreReadYourWritesWindow(time.Minute), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, []pgAdapter.Adapter{replica}, store.replicas.adapters)
+	assert.Equal(t, time.Minute, store.replicas.window)
+}
+
+func TestWithTokenStoreRetryPolicy(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Nil(t, store.retry.policy)
+
+	store, err = NewTokenStore(nil, WithTokenStoreRetryPolicy(DefaultRetryPolicy()), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	require.NotNil(t, store.retry.policy)
+	assert.Equal(t, DefaultRetryPolicy(), *store.retry.policy)
+}
+
+func TestWithTokenStoreTimeouts(t *testing.T) {
+	timeouts := OperationTimeouts{Read: time.Second, Write: 2 * time.Second, GC: time.Minute, Migration: time.Hour}
+
+	store, err := NewTokenStore(nil, WithTokenStoreTimeouts(timeouts), WithTokenStoreSlowQueryThreshold(time.Second), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, timeouts, store.limits.timeouts)
+	assert.Equal(t, time.Second, store.limits.slowThreshold)
+}
+
+func TestWithTokenStoreStatsLimits(t *testing.T) {
+	store, err := NewTokenStore(nil, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, newStatsSampler(), store.stats)
+
+	store, err = NewTokenStore(nil, WithTokenStoreStatsLimits(5, 100), WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	assert.Equal(t, statsSampler{topLimit: 5, exactCountLimit: 100}, store.stats)
+}
@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -118,6 +119,26 @@ func TestTokenStore_gc(t *testing.T) {
 	assert.True(t, 5 >= execCalls)
 }
 
+func TestRowExpiresAt(t *testing.T) {
+	now := time.Now()
+
+	assert.Nil(t, credentialExpiresAt(now, 0))
+	assert.Equal(t, now.Add(time.Minute), *credentialExpiresAt(now, time.Minute))
+
+	item := &TokenStoreItem{
+		Access:           "access",
+		AccessExpiresAt:  credentialExpiresAt(now, time.Minute),
+		Refresh:          "refresh",
+		RefreshExpiresAt: credentialExpiresAt(now, time.Hour),
+		// not set credential expiration is ignored
+		CodeExpiresAt: credentialExpiresAt(now, 2*time.Hour),
+	}
+	assert.Equal(t, now.Add(time.Hour), *rowExpiresAt(item))
+
+	item.RefreshExpiresAt = nil
+	assert.Nil(t, rowExpiresAt(item))
+}
+
 func generateTokenTableName() string {
 	return fmt.Sprintf("token_%d", time.Now().UnixNano())
 }
@@ -274,6 +295,7 @@ func runTokenStoreTest(t *testing.T, store *TokenStore, l *memoryLogger) {
 	runTokenStoreCodeTest(t, store)
 	runTokenStoreAccessTest(t, store)
 	runTokenStoreRefreshTest(t, store)
+	runTokenStoreTenantTest(t, store)
 
 	// sleep for a while just to wait for GC run for sure to ensure there were no errors there
 	time.Sleep(3 * time.Second)
@@ -341,6 +363,200 @@ func runTokenStoreRefreshTest(t *testing.T, store *TokenStore) {
 	assert.Equal(t, pgAdapter.ErrNoRows, err)
 }
 
+func runTokenStoreTenantTest(t *testing.T, store *TokenStore) {
+	code := fmt.Sprintf("tenant access %s", time.Now().String())
+	ctx := WithTenant(context.Background(), "tenant-a")
+	otherCtx := WithTenant(context.Background(), "tenant-b")
+
+	tokenCode := models.NewToken()
+	tokenCode.SetAccess(code)
+	tokenCode.SetAccessCreateAt(time.Now())
+	tokenCode.SetAccessExpiresIn(time.Minute)
+	require.NoError(t, store.Create(ctx, tokenCode))
+
+	_, err := store.GetByAccess(otherCtx, code)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	_, err = store.GetByAccess(context.Background(), code)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	require.NoError(t, store.RemoveByAccess(otherCtx, code))
+
+	token, err := store.GetByAccess(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, code, token.GetAccess())
+
+	require.NoError(t, store.RemoveByAccess(ctx, code))
+
+	_, err = store.GetByAccess(ctx, code)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
+
+func TestTokenStore_RefreshIdleTimeout(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	store, err := NewTokenStore(
+		pgx4adapter.NewPool(pgXConnPool),
+		WithTokenStoreTableName(generateTokenTableName()),
+		WithTokenStoreGCDisabled(),
+		WithTokenStoreRefreshIdleTimeout(time.Second),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	refresh := fmt.Sprintf("refresh %s", time.Now().String())
+	ctx := context.Background()
+
+	tokenCode := models.NewToken()
+	tokenCode.SetRefresh(refresh)
+	tokenCode.SetRefreshCreateAt(time.Now())
+	tokenCode.SetRefreshExpiresIn(time.Minute)
+	require.NoError(t, store.Create(ctx, tokenCode))
+
+	// every lookup extends inactivity deadline, so the token outlives initial idle timeout
+	for i := 0; i < 2; i++ {
+		time.Sleep(600 * time.Millisecond)
+
+		token, err := store.GetByRefresh(ctx, refresh)
+		require.NoError(t, err)
+		assert.Equal(t, refresh, token.GetRefresh())
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = store.GetByRefresh(ctx, refresh)
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
+
+func TestTokenStore_PerKindExpiration(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	store, err := NewTokenStore(
+		pgx4adapter.NewPool(pgXConnPool),
+		WithTokenStoreTableName(generateTokenTableName()),
+		WithTokenStoreGCDisabled(),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	ctx := context.Background()
+	suffix := time.Now().String()
+
+	// expired access token with alive refresh token
+	expiredAccess := models.NewToken()
+	expiredAccess.SetAccess("access expired " + suffix)
+	expiredAccess.SetAccessCreateAt(time.Now().Add(-time.Hour))
+	expiredAccess.SetAccessExpiresIn(time.Minute)
+	expiredAccess.SetRefresh("refresh alive " + suffix)
+	expiredAccess.SetRefreshCreateAt(time.Now())
+	expiredAccess.SetRefreshExpiresIn(time.Hour)
+	require.NoError(t, store.Create(ctx, expiredAccess))
+
+	_, err = store.GetByAccess(ctx, expiredAccess.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	token, err := store.GetByRefresh(ctx, expiredAccess.GetRefresh())
+	require.NoError(t, err)
+	assert.Equal(t, expiredAccess.GetAccess(), token.GetAccess())
+
+	// never expiring refresh token
+	neverExpires := models.NewToken()
+	neverExpires.SetAccess("access never expires " + suffix)
+	neverExpires.SetAccessCreateAt(time.Now().Add(-time.Hour))
+	neverExpires.SetAccessExpiresIn(time.Minute)
+	neverExpires.SetRefresh("refresh never expires " + suffix)
+	neverExpires.SetRefreshCreateAt(time.Now().Add(-time.Hour))
+	require.NoError(t, store.Create(ctx, neverExpires))
+
+	// dead row
+	dead := models.NewToken()
+	dead.SetAccess("access dead " + suffix)
+	dead.SetAccessCreateAt(time.Now().Add(-time.Hour))
+	dead.SetAccessExpiresIn(time.Minute)
+	dead.SetRefresh("refresh dead " + suffix)
+	dead.SetRefreshCreateAt(time.Now().Add(-time.Hour))
+	dead.SetRefreshExpiresIn(time.Minute)
+	require.NoError(t, store.Create(ctx, dead))
+
+	store.clean()
+
+	_, err = store.GetByRefresh(ctx, expiredAccess.GetRefresh())
+	require.NoError(t, err)
+
+	token, err = store.GetByRefresh(ctx, neverExpires.GetRefresh())
+	require.NoError(t, err)
+	assert.Equal(t, neverExpires.GetAccess(), token.GetAccess())
+
+	var item TokenStoreItem
+	err = store.adapter.SelectOne(ctx, &item, fmt.Sprintf("SELECT * FROM %s WHERE refresh = $1", store.tableName), dead.GetRefresh())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+}
+
+func TestTokenStore_LegacyExpiration(t *testing.T) {
+	pgXConnPool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pgXConnPool.Close()
+
+	ctx := context.Background()
+	tableName := generateTokenTableName()
+
+	// table created before per-kind expiration tracking
+	_, err = pgXConnPool.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE %[1]s (
+	id         BIGSERIAL   NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	code       TEXT        NOT NULL,
+	access     TEXT        NOT NULL,
+	refresh    TEXT        NOT NULL,
+	data       JSONB       NOT NULL,
+	CONSTRAINT %[1]s_pkey PRIMARY KEY (id)
+)`, tableName))
+	require.NoError(t, err)
+
+	now := time.Now()
+	token := models.NewToken()
+	token.SetAccess("access")
+	token.SetAccessCreateAt(now)
+	token.SetAccessExpiresIn(time.Hour)
+	token.SetRefresh("refresh")
+	token.SetRefreshCreateAt(now)
+	token.SetRefreshExpiresIn(0)
+
+	data, err := json.Marshal(token)
+	require.NoError(t, err)
+	_, err = pgXConnPool.Exec(
+		ctx,
+		fmt.Sprintf("INSERT INTO %s (created_at, expires_at, code, access, refresh, data) VALUES ($1, $2, '', $3, $4, $5)", tableName),
+		now, now.Add(time.Hour), token.GetAccess(), token.GetRefresh(), data,
+	)
+	require.NoError(t, err)
+
+	store, err := NewTokenStore(NewPGXPool(pgXConnPool), WithTokenStoreTableName(tableName), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	var accessExpiresAt, refreshExpiresAt sql.NullTime
+	require.NoError(t, pgXConnPool.QueryRow(
+		ctx,
+		fmt.Sprintf("SELECT access_expires_at, refresh_expires_at FROM %s", tableName),
+	).Scan(&accessExpiresAt, &refreshExpiresAt))
+	require.True(t, accessExpiresAt.Valid)
+	assert.WithinDuration(t, now.Add(time.Hour), accessExpiresAt.Time, time.Millisecond)
+	// zero lifetime refresh token never expires
+	assert.False(t, refreshExpiresAt.Valid)
+}
+
 func runClientStoreTest(t *testing.T, store *ClientStore) {
 	originalClient := &models.Client{
 		ID:     fmt.Sprintf("id %s", time.Now().String()),
@@ -350,7 +566,7 @@ func runClientStoreTest(t *testing.T, store *ClientStore) {
 	}
 	ctx := context.Background()
 
-	require.NoError(t, store.Create(originalClient))
+	require.NoError(t, store.Create(ctx, originalClient))
 
 	client, err := store.GetByID(ctx, originalClient.GetID())
 	require.NoError(t, err)
@@ -358,4 +574,20 @@ func runClientStoreTest(t *testing.T, store *ClientStore) {
 	assert.Equal(t, originalClient.GetSecret(), client.GetSecret())
 	assert.Equal(t, originalClient.GetDomain(), client.GetDomain())
 	assert.Equal(t, originalClient.GetUserID(), client.GetUserID())
+
+	_, err = store.GetByID(WithTenant(ctx, "other tenant"), originalClient.GetID())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	updatedClient := *originalClient
+	updatedClient.Domain = fmt.Sprintf("updated domain %s", time.Now().String())
+	require.NoError(t, store.Update(ctx, &updatedClient))
+
+	client, err = store.GetByID(ctx, originalClient.GetID())
+	require.NoError(t, err)
+	assert.Equal(t, updatedClient.GetDomain(), client.GetDomain())
+
+	require.NoError(t, store.RemoveByID(ctx, originalClient.GetID()))
+
+	_, err = store.GetByID(ctx, originalClient.GetID())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
 }
@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenUsage is the token usage statistics item
+type TokenUsage struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Access     string     `json:"access"`
+	Refresh    string     `json:"refresh"`
+	ClientID   string     `json:"client_id"`
+	UserID     string     `json:"user_id"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UseCount   int64      `json:"use_count"`
+}
+
+type pendingUsage struct {
+	ID     int64     `json:"id"`
+	UsedAt time.Time `json:"used_at"`
+	Count  int64     `json:"count"`
+}
+
+// usageTracker accumulates token usage in memory, so that it can be written with a single statement
+type usageTracker struct {
+	mu      sync.Mutex
+	pending map[int64]*pendingUsage
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{pending: make(map[int64]*pendingUsage)}
+}
+
+func (u *usageTracker) track(id int64, usedAt time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.pending[id]
+	if !ok {
+		p = &pendingUsage{ID: id}
+		u.pending[id] = p
+	}
+
+	p.Count++
+	if usedAt.After(p.UsedAt) {
+		p.UsedAt = usedAt
+	}
+}
+
+func (u *usageTracker) drain() []*pendingUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage := make([]*pendingUsage, 0, len(u.pending))
+	for _, p := range u.pending {
+		usage = append(usage, p)
+	}
+	u.pending = make(map[int64]*pendingUsage)
+
+	return usage
+}
+
+func (s *TokenStore) trackUsage(id int64) {
+	if s.usage != nil {
+		s.usage.track(id, time.Now())
+	}
+}
+
+func (s *TokenStore) usageFlush() {
+	for range s.usageTicker.C {
+		s.flushUsage(context.Background())
+	}
+}
+
+// flushUsage writes accumulated token usage with a single statement
+func (s *TokenStore) flushUsage(ctx context.Context) {
+	usage := s.usage.drain()
+	if len(usage) == 0 {
+		return
+	}
+
+	ctx, done := s.limits.start(ctx, opWrite, "token usage flush")
+	defer done()
+
+	buf, err := json.Marshal(usage)
+	if err != nil {
+		s.logger.Printf("Error while encoding token usage: %+v", err)
+		return
+	}
+
+	err = s.maintenance().Exec(ctx, fmt.Sprintf(`
+UPDATE %s t SET
+	last_used_at = GREATEST(t.last_used_at, u.used_at),
+	use_count = t.use_count + u.count
+FROM json_to_recordset($1::json) AS u(id BIGINT, used_at TIMESTAMPTZ, count BIGINT)
+WHERE t.id = u.id`, s.tableName), string(buf))
+	if err != nil {
+		s.logger.Printf("Error while writing token usage: %+v", err)
+	}
+}
+
+// IdleTokens returns up to limit access and refresh tokens that were not used, or were not used since creation,
+// for at least idleFor duration, the ones idle for the longest time go first.
+// Usage is written periodically, so the tokens used within the last usage flush interval may be reported as idle.
+func (s *TokenStore) IdleTokens(ctx context.Context, idleFor time.Duration, limit int) ([]*TokenUsage, error) {
+	ctx, done := s.limits.start(ctx, opRead, "idle tokens lookup")
+	defer done()
+
+	now := time.Now()
+
+	var items []*TokenUsage
+	err := selectAll(ctx, s.db(ctx), &items, fmt.Sprintf(`
+SELECT id, created_at, expires_at, access, refresh, client_id, user_id, last_used_at, use_count
+FROM %s
+WHERE code = '' AND (expires_at IS NULL OR expires_at > $1) AND COALESCE(last_used_at, created_at) <= $2 AND tenant_id = $3
+ORDER BY COALESCE(last_used_at, created_at)
+LIMIT $4`, s.tableName), now, now.Add(-idleFor), resolveTenant(ctx, s.tenantID), limit)
+
+	return items, err
+}
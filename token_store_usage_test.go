@@ -0,0 +1,108 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+func TestUsageTracker(t *testing.T) {
+	tracker := newUsageTracker()
+	now := time.Now()
+
+	tracker.track(1, now)
+	tracker.track(1, now.Add(-time.Second))
+	tracker.track(2, now)
+
+	usage := tracker.drain()
+	require.Len(t, usage, 2)
+	for _, u := range usage {
+		if u.ID == 1 {
+			assert.Equal(t, int64(2), u.Count)
+		} else {
+			assert.Equal(t, int64(1), u.Count)
+		}
+		assert.Equal(t, now, u.UsedAt)
+	}
+
+	assert.Empty(t, tracker.drain())
+}
+
+func TestTokenStore_flushUsage(t *testing.T) {
+	adapter := new(mockAdapter)
+
+	var execCalls int
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		execCalls++
+	})
+
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled(), WithTokenStoreUsageTracking(time.Hour))
+	require.NoError(t, err)
+
+	// nothing to write
+	store.flushUsage(context.Background())
+	assert.Equal(t, 0, execCalls)
+
+	store.trackUsage(1)
+	store.trackUsage(1)
+	require.NoError(t, store.Close())
+	assert.Equal(t, 1, execCalls)
+}
+
+func TestTokenStore_IdleTokens(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	store, err := NewTokenStore(
+		pgx4adapter.NewPool(pool),
+		WithTokenStoreTableName(generateTokenTableName()),
+		WithTokenStoreGCDisabled(),
+		WithTokenStoreUsageTracking(time.Hour),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	ctx := context.Background()
+	var accesses []string
+	for i := 0; i < 2; i++ {
+		access := fmt.Sprintf("access %d %s", i, time.Now().String())
+		accesses = append(accesses, access)
+
+		token := models.NewToken()
+		token.SetAccess(access)
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Minute)
+		require.NoError(t, store.Create(ctx, token))
+	}
+
+	_, err = store.GetByAccess(ctx, accesses[0])
+	require.NoError(t, err)
+	_, err = store.GetByAccess(ctx, accesses[0])
+	require.NoError(t, err)
+	store.flushUsage(ctx)
+
+	idle, err := store.IdleTokens(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, idle, 2)
+	assert.Equal(t, accesses[1], idle[0].Access)
+	assert.Nil(t, idle[0].LastUsedAt)
+	assert.Equal(t, int64(0), idle[0].UseCount)
+	assert.Equal(t, accesses[0], idle[1].Access)
+	assert.NotNil(t, idle[1].LastUsedAt)
+	assert.Equal(t, int64(2), idle[1].UseCount)
+
+	idle, err = store.IdleTokens(ctx, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Empty(t, idle)
+}
@@ -0,0 +1,444 @@
+package pg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// transferBatchSize is the number of rows exported or imported at once
+const transferBatchSize = 1000
+
+// Copier is the adapter able to bulk load rows with COPY, see NewPGXPool and NewPGXTx for the implementations
+type Copier interface {
+	pgAdapter.Adapter
+
+	// CopyFrom loads rows into the table columns, returns the number of loaded rows
+	CopyFrom(ctx context.Context, tableName string, columns []string, rows [][]interface{}) (int64, error)
+}
+
+// TokenRecord is the token NDJSON record of the bulk export and import. Token is the token information JSON,
+// e.g. models.Token, as it is stored by the other go-oauth2 stores.
+type TokenRecord struct {
+	CreatedAt     time.Time       `json:"created_at"`
+	ExpiresAt     *time.Time      `json:"expires_at,omitempty"`
+	IdleExpiresAt *time.Time      `json:"idle_expires_at,omitempty"`
+	Token         json.RawMessage `json:"token"`
+}
+
+// ClientRecord is the client NDJSON record of the bulk export and import. Client is the client information JSON,
+// e.g. models.Client or Client.
+type ClientRecord struct {
+	Disabled       bool            `json:"disabled,omitempty"`
+	DisabledReason string          `json:"disabled_reason,omitempty"`
+	ExpiresAt      *time.Time      `json:"expires_at,omitempty"`
+	Client         json.RawMessage `json:"client"`
+}
+
+// TransferProgress is the bulk export or import progress
+type TransferProgress struct {
+	// Rows is the number of processed table rows or NDJSON lines
+	Rows int64
+	// Transferred is the number of exported or imported rows
+	Transferred int64
+	// Skipped is the number of skipped expired tokens
+	Skipped int64
+	// Errors are the rows failed to be exported or imported
+	Errors []*RowError
+}
+
+// RowError is the error of the single row export or import, row is the NDJSON line number on import
+// and the table row id on export
+type RowError struct {
+	Row string
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %s: %s", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// TransferProgressFunc is called after every exported or imported batch of rows
+type TransferProgressFunc func(progress TransferProgress)
+
+func (p *TransferProgress) report(fn TransferProgressFunc) {
+	if fn != nil {
+		fn(*p)
+	}
+}
+
+// copyRows loads rows in a transaction with COPY if the transaction adapter supports it,
+// or inserts them one by one otherwise
+func copyRows(ctx context.Context, storeAdapter pgAdapter.Adapter, tableName string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	return runInTx(ctx, storeAdapter, func(ctx context.Context, tx pgAdapter.Adapter) error {
+		if c, ok := tx.(Copier); ok {
+			_, err := c.CopyFrom(ctx, tableName, columns, rows)
+			return err
+		}
+
+		for _, row := range rows {
+			if err := tx.Exec(ctx, query, row...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// importNDJSON reads NDJSON lines, turns them into rows with parse and loads them in batches with load.
+// Nil row returned by parse means the line is skipped.
+func importNDJSON(
+	ctx context.Context,
+	r io.Reader,
+	progressFn TransferProgressFunc,
+	parse func(line []byte) ([]interface{}, error),
+	load func(rows [][]interface{}) error,
+) (*TransferProgress, error) {
+	progress := new(TransferProgress)
+	reader := bufio.NewReader(r)
+	batch := make([][]interface{}, 0, transferBatchSize)
+
+	flush := func() error {
+		if err := load(batch); err != nil {
+			return err
+		}
+		progress.Transferred += int64(len(batch))
+		batch = batch[:0]
+		progress.report(progressFn)
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return progress, readErr
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			progress.Rows++
+			row, err := parse(line)
+			switch {
+			case err != nil:
+				progress.Errors = append(progress.Errors, &RowError{Row: fmt.Sprint(progress.Rows), Err: err})
+			case row == nil:
+				progress.Skipped++
+			default:
+				batch = append(batch, row)
+			}
+		}
+
+		if readErr == io.EOF {
+			if len(batch) > 0 {
+				return progress, flush()
+			}
+			progress.report(progressFn)
+			return progress, nil
+		}
+		if len(batch) == transferBatchSize {
+			if err := flush(); err != nil {
+				return progress, err
+			}
+		}
+	}
+}
+
+// writeNDJSON writes the records as NDJSON lines
+func writeNDJSON(w io.Writer, records []interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenImportColumns are the token table columns loaded on import
+var tokenImportColumns = []string{
+	"tenant_id", "created_at", "expires_at", "code", "access", "refresh", "client_id", "user_id",
+	"idle_expires_at", "code_expires_at", "access_expires_at", "refresh_expires_at",
+	"code_challenge", "code_challenge_method", "nonce", "redirect_uri", "data",
+}
+
+type tokenExportItem struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	IdleExpiresAt *time.Time `json:"idle_expires_at"`
+	Data          []byte     `json:"data"`
+}
+
+// dataExport returns the select expression of the data column encoded as base64, so that it is decoded into bytes
+// regardless of the column type
+func dataExport(codec Codec) string {
+	if codec.DataType() == "BYTEA" {
+		return "encode(data, 'base64') AS data"
+	}
+	return "encode(convert_to(data::text, 'UTF8'), 'base64') AS data"
+}
+
+// Export streams not expired tokens of the store or context tenant to w as TokenRecord NDJSON lines.
+// Tokens that can not be decoded are reported as row errors and are not exported.
+func (s *TokenStore) Export(ctx context.Context, w io.Writer, progressFn TransferProgressFunc) (*TransferProgress, error) {
+	progress := new(TransferProgress)
+	tenantID := resolveTenant(ctx, s.tenantID)
+
+	var lastID int64
+	for {
+		var items []*tokenExportItem
+		if err := selectAll(ctx, s.db(ctx), &items, fmt.Sprintf(`
+SELECT id, created_at, expires_at, idle_expires_at, %s FROM %s
+WHERE tenant_id = $1 AND id > $2 AND (expires_at IS NULL OR expires_at > $3) AND (idle_expires_at IS NULL OR idle_expires_at > $3)
+ORDER BY id LIMIT %d`, dataExport(s.codec), s.tableName, transferBatchSize), tenantID, lastID, time.Now()); err != nil {
+			return progress, err
+		}
+		if len(items) == 0 {
+			progress.report(progressFn)
+			return progress, nil
+		}
+
+		records := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			lastID = item.ID
+			progress.Rows++
+
+			token, err := s.exportToken(item.Data)
+			if err != nil {
+				progress.Errors = append(progress.Errors, &RowError{Row: fmt.Sprint(item.ID), Err: err})
+				continue
+			}
+			records = append(records, &TokenRecord{CreatedAt: item.CreatedAt, ExpiresAt: item.ExpiresAt, IdleExpiresAt: item.IdleExpiresAt, Token: token})
+		}
+
+		if err := writeNDJSON(w, records); err != nil {
+			return progress, err
+		}
+		progress.Transferred += int64(len(records))
+		progress.report(progressFn)
+	}
+}
+
+func (s *TokenStore) exportToken(data []byte) (json.RawMessage, error) {
+	info, err := s.toTokenInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(info)
+}
+
+// Import loads TokenRecord NDJSON lines from r into the store or context tenant, with COPY if the store adapter
+// is Copier. Creation and expiration times are preserved, already expired tokens are skipped. Lines that can not
+// be decoded are reported as row errors and are not imported, failed batch load stops the import.
+// Imported tokens are not audited.
+func (s *TokenStore) Import(ctx context.Context, r io.Reader, progressFn TransferProgressFunc) (*TransferProgress, error) {
+	now := time.Now()
+
+	return importNDJSON(ctx, r, progressFn, func(line []byte) ([]interface{}, error) {
+		var record TokenRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		if len(record.Token) == 0 {
+			return nil, errors.New("token is missing")
+		}
+
+		info := s.newInfo()
+		if err := json.Unmarshal(record.Token, info); err != nil {
+			return nil, err
+		}
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+
+		item, err := s.newItem(ctx, info, record.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if record.ExpiresAt != nil {
+			item.ExpiresAt = record.ExpiresAt
+		}
+		if record.IdleExpiresAt != nil {
+			item.IdleExpiresAt = record.IdleExpiresAt
+		}
+		if expired(item.ExpiresAt, now) || expired(item.IdleExpiresAt, now) {
+			return nil, nil
+		}
+
+		return []interface{}{
+			item.TenantID,
+			item.CreatedAt,
+			item.ExpiresAt,
+			item.Code,
+			item.Access,
+			item.Refresh,
+			item.ClientID,
+			item.UserID,
+			item.IdleExpiresAt,
+			item.CodeExpiresAt,
+			item.AccessExpiresAt,
+			item.RefreshExpiresAt,
+			item.CodeChallenge,
+			item.CodeChallengeMethod,
+			item.Nonce,
+			item.RedirectURI,
+			item.Data,
+		}, nil
+	}, func(rows [][]interface{}) error {
+		return copyRows(ctx, s.db(ctx), s.tableName, tokenImportColumns, rows)
+	})
+}
+
+func expired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(now)
+}
+
+// clientImportColumns are the client table columns loaded on import
+var clientImportColumns = []string{
+	"id", "tenant_id", "secret", "domain", "public", "user_id", "redirect_uris", "grant_types", "scopes",
+	"access_token_lifetime", "refresh_token_lifetime", "disabled", "disabled_reason", "expires_at", "data",
+}
+
+type clientExportItem struct {
+	ID             string     `json:"id"`
+	Disabled       bool       `json:"disabled"`
+	DisabledReason string     `json:"disabled_reason"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Data           []byte     `json:"data"`
+}
+
+// Export streams clients of the store or context tenant to w as ClientRecord NDJSON lines, including disabled
+// and expired ones. Clients that can not be decoded are reported as row errors and are not exported.
+func (s *ClientStore) Export(ctx context.Context, w io.Writer, progressFn TransferProgressFunc) (*TransferProgress, error) {
+	progress := new(TransferProgress)
+	tenantID := resolveTenant(ctx, s.tenantID)
+
+	var lastID string
+	for {
+		var items []*clientExportItem
+		if err := selectAll(ctx, s.db(ctx), &items, fmt.Sprintf(`
+SELECT id, disabled, disabled_reason, expires_at, %s FROM %s
+WHERE tenant_id = $1 AND id > $2
+ORDER BY id LIMIT %d`, dataExport(s.codec), s.tableName, transferBatchSize), tenantID, lastID); err != nil {
+			return progress, err
+		}
+		if len(items) == 0 {
+			progress.report(progressFn)
+			return progress, nil
+		}
+
+		records := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			lastID = item.ID
+			progress.Rows++
+
+			client, err := s.exportClient(item.Data)
+			if err != nil {
+				progress.Errors = append(progress.Errors, &RowError{Row: item.ID, Err: err})
+				continue
+			}
+			records = append(records, &ClientRecord{Disabled: item.Disabled, DisabledReason: item.DisabledReason, ExpiresAt: item.ExpiresAt, Client: client})
+		}
+
+		if err := writeNDJSON(w, records); err != nil {
+			return progress, err
+		}
+		progress.Transferred += int64(len(records))
+		progress.report(progressFn)
+	}
+}
+
+func (s *ClientStore) exportClient(data []byte) (json.RawMessage, error) {
+	info, err := s.toClientInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(info)
+}
+
+// Import loads ClientRecord NDJSON lines from r into the store or context tenant, with COPY if the store adapter
+// is Copier. Lines that can not be decoded are reported as row errors and are not imported, failed batch load,
+// e.g. because of already existing client, stops the import. Imported clients are not audited.
+func (s *ClientStore) Import(ctx context.Context, r io.Reader, progressFn TransferProgressFunc) (*TransferProgress, error) {
+	tenantID := resolveTenant(ctx, s.tenantID)
+
+	return importNDJSON(ctx, r, progressFn, func(line []byte) ([]interface{}, error) {
+		var record ClientRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		if len(record.Client) == 0 {
+			return nil, errors.New("client is missing")
+		}
+
+		info := s.newInfo()
+		if err := json.Unmarshal(record.Client, info); err != nil {
+			return nil, err
+		}
+
+		return s.importRow(tenantID, info, record)
+	}, func(rows [][]interface{}) error {
+		return copyRows(ctx, s.db(ctx), s.tableName, clientImportColumns, rows)
+	})
+}
+
+func (s *ClientStore) importRow(tenantID string, info oauth2.ClientInfo, record ClientRecord) ([]interface{}, error) {
+	if info.GetID() == "" {
+		return nil, errors.New("client id is missing")
+	}
+
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := newClientColumns(info)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		info.GetID(),
+		tenantID,
+		info.GetSecret(),
+		info.GetDomain(),
+		info.IsPublic(),
+		info.GetUserID(),
+		columns.RedirectURIs,
+		columns.GrantTypes,
+		columns.Scopes,
+		columns.AccessTokenLifetime,
+		columns.RefreshTokenLifetime,
+		record.Disabled,
+		record.DisabledReason,
+		record.ExpiresAt,
+		data,
+	}, nil
+}
@@ -0,0 +1,189 @@
+package pg
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/sqladapter"
+)
+
+func TestImportNDJSON(t *testing.T) {
+	input := strings.Repeat("ok\n", transferBatchSize) + "\nskip\nbroken\nok"
+
+	var (
+		loaded   []int
+		reported []TransferProgress
+	)
+	progress, err := importNDJSON(context.Background(), strings.NewReader(input), func(p TransferProgress) {
+		reported = append(reported, p)
+	}, func(line []byte) ([]interface{}, error) {
+		switch string(line) {
+		case "ok":
+			return []interface{}{string(line)}, nil
+		case "skip":
+			return nil, nil
+		default:
+			return nil, errors.New("broken line")
+		}
+	}, func(rows [][]interface{}) error {
+		loaded = append(loaded, len(rows))
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{transferBatchSize, 1}, loaded)
+	assert.Equal(t, int64(transferBatchSize+3), progress.Rows)
+	assert.Equal(t, int64(transferBatchSize+1), progress.Transferred)
+	assert.Equal(t, int64(1), progress.Skipped)
+	require.Len(t, progress.Errors, 1)
+	assert.Equal(t, fmt.Sprintf("row %d: broken line", transferBatchSize+2), progress.Errors[0].Error())
+
+	require.Len(t, reported, 2)
+	assert.Equal(t, int64(transferBatchSize), reported[0].Transferred)
+	assert.Equal(t, *progress, reported[1])
+
+	// failed batch load stops the import
+	_, err = importNDJSON(context.Background(), strings.NewReader("ok\nok"), nil, func(line []byte) ([]interface{}, error) {
+		return []interface{}{string(line)}, nil
+	}, func(rows [][]interface{}) error {
+		return errors.New("load failed")
+	})
+	assert.EqualError(t, err, "load failed")
+}
+
+func TestTokenStore_Import_insert(t *testing.T) {
+	adapter := new(mockAdapter)
+	adapter.On("Exec", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		assert.True(t, strings.HasPrefix(args.Get(1).(string), "INSERT INTO oauth2_tokens (tenant_id, created_at, expires_at,"))
+		assert.Len(t, args.Get(2), len(tokenImportColumns))
+	})
+
+	store, err := NewTokenStore(adapter, WithTokenStoreInitTableDisabled(), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+
+	createdAt := time.Now().Add(-time.Hour).UTC()
+	input := fmt.Sprintf(`{"created_at":%q,"token":{"ClientID":"client","Access":"live","AccessCreateAt":%q,"AccessExpiresIn":7200000000000}}
+{"created_at":%q,"token":{"ClientID":"client","Access":"expired","AccessCreateAt":%q,"AccessExpiresIn":60000000000}}
+{"created_at":%q}
+`, createdAt.Format(time.RFC3339Nano), createdAt.Format(time.RFC3339Nano), createdAt.Format(time.RFC3339Nano), createdAt.Format(time.RFC3339Nano), createdAt.Format(time.RFC3339Nano))
+
+	progress, err := store.Import(context.Background(), strings.NewReader(input), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), progress.Rows)
+	assert.Equal(t, int64(1), progress.Transferred)
+	assert.Equal(t, int64(1), progress.Skipped)
+	require.Len(t, progress.Errors, 1)
+	assert.Equal(t, "row 3: token is missing", progress.Errors[0].Error())
+
+	adapter.AssertNumberOfCalls(t, "Exec", 1)
+	row := adapter.Calls[0].Arguments.Get(2).([]interface{})
+	assert.Equal(t, createdAt, row[1].(time.Time).UTC())
+	assert.Equal(t, "live", row[4])
+}
+
+func runTransferTest(t *testing.T, adapter pgAdapter.Adapter) {
+	ctx := context.Background()
+
+	tokenStore, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tokenStore.Close())
+	}()
+
+	clientStore, err := NewClientStore(adapter, WithClientStoreTableName(generateClientTableName()))
+	require.NoError(t, err)
+
+	require.NoError(t, clientStore.Create(ctx, &models.Client{ID: "client-a", Secret: "secret", Domain: "https://a.example.com"}))
+	require.NoError(t, clientStore.Create(ctx, &models.Client{ID: "client-b", Public: true}))
+	require.NoError(t, clientStore.Disable(ctx, "client-b", "test", false))
+
+	var accesses []string
+	for i := 0; i < 3; i++ {
+		token := models.NewToken()
+		token.SetClientID("client-a")
+		token.SetAccess(fmt.Sprintf("access %d %s", i, time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Hour)
+		require.NoError(t, tokenStore.Create(ctx, token))
+		accesses = append(accesses, token.GetAccess())
+	}
+
+	expiredToken := models.NewToken()
+	expiredToken.SetAccess(fmt.Sprintf("expired %s", time.Now().String()))
+	expiredToken.SetAccessCreateAt(time.Now())
+	expiredToken.SetAccessExpiresIn(time.Nanosecond)
+	require.NoError(t, tokenStore.Create(ctx, expiredToken))
+
+	var tokens, clients bytes.Buffer
+	progress, err := tokenStore.Export(ctx, &tokens, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), progress.Transferred)
+	assert.Empty(t, progress.Errors)
+
+	progress, err = clientStore.Export(ctx, &clients, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Transferred)
+
+	importedTokenStore, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, importedTokenStore.Close())
+	}()
+
+	importedClientStore, err := NewClientStore(adapter, WithClientStoreTableName(generateClientTableName()))
+	require.NoError(t, err)
+
+	progress, err = importedTokenStore.Import(ctx, &tokens, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), progress.Transferred)
+	assert.Empty(t, progress.Errors)
+
+	progress, err = importedClientStore.Import(ctx, &clients, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Transferred)
+
+	for _, access := range accesses {
+		info, err := importedTokenStore.GetByAccess(ctx, access)
+		require.NoError(t, err)
+		assert.Equal(t, "client-a", info.GetClientID())
+	}
+
+	client, err := importedClientStore.GetByID(ctx, "client-a")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", client.GetSecret())
+	assert.Equal(t, "https://a.example.com", client.GetDomain())
+
+	_, err = importedClientStore.GetByID(ctx, "client-b")
+	assert.Equal(t, ErrClientDisabled, err)
+}
+
+func TestTransfer_PGXPool(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	runTransferTest(t, NewPGXPool(pool))
+}
+
+func TestTransfer_SQL(t *testing.T) {
+	conn, err := sql.Open("pgx", uri)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, conn.Close())
+	}()
+
+	runTransferTest(t, sqladapter.New(conn))
+}
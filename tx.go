@@ -0,0 +1,62 @@
+package pg
+
+import (
+	"context"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+// Transactor is the adapter able to run a function in a new transaction,
+// see NewPGXPool and NewSQLDB for the implementations
+type Transactor interface {
+	pgAdapter.Adapter
+
+	// InTx runs fn in a new transaction, transaction is committed if fn returns nil and rolled back otherwise
+	InTx(ctx context.Context, fn func(tx pgAdapter.Adapter) error) error
+}
+
+type txCtxKey struct{}
+
+// WithTransaction returns a copy of the context that binds store operations made with it to the transaction,
+// so that they are committed or rolled back together with the caller changes.
+// Use NewPGXTx or NewSQLTx to get the transaction adapter.
+func WithTransaction(ctx context.Context, tx pgAdapter.Adapter) context.Context {
+	return context.WithValue(ctx, txCtxKey{}, tx)
+}
+
+// TransactionFromContext returns the transaction adapter stored in the context, if any
+func TransactionFromContext(ctx context.Context) (pgAdapter.Adapter, bool) {
+	tx, ok := ctx.Value(txCtxKey{}).(pgAdapter.Adapter)
+	return tx, ok
+}
+
+// resolveAdapter returns the transaction adapter bound to the context or the store adapter
+func resolveAdapter(ctx context.Context, storeAdapter pgAdapter.Adapter) pgAdapter.Adapter {
+	if tx, ok := TransactionFromContext(ctx); ok {
+		return tx
+	}
+	return storeAdapter
+}
+
+// runInTx runs fn in the transaction bound to the context, or in a new one if the store adapter is Transactor.
+// Otherwise fn runs on the store adapter as is, statements are not atomic then. Row-level security session adapter
+// wraps the transaction bound to the context already, so it sets its setting in either transaction.
+func runInTx(ctx context.Context, storeAdapter pgAdapter.Adapter, fn func(ctx context.Context, tx pgAdapter.Adapter) error) error {
+	if s, ok := storeAdapter.(sessionAdapter); ok {
+		return s.InTx(ctx, func(tx pgAdapter.Adapter) error {
+			return fn(WithTransaction(ctx, tx), tx)
+		})
+	}
+
+	if tx, ok := TransactionFromContext(ctx); ok {
+		return fn(ctx, tx)
+	}
+
+	if t, ok := storeAdapter.(Transactor); ok {
+		return t.InTx(ctx, func(tx pgAdapter.Adapter) error {
+			return fn(WithTransaction(ctx, tx), tx)
+		})
+	}
+
+	return fn(ctx, storeAdapter)
+}
@@ -0,0 +1,129 @@
+package pg
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	pgxHelpers "github.com/vgarvardt/pgx-helpers/v4"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/pgx4adapter"
+)
+
+// PGXPool is the PGx pool adapter able to run store operations in a transaction
+type PGXPool struct {
+	*pgx4adapter.Pool
+	pool *pgxpool.Pool
+}
+
+// NewPGXPool instantiates PGx pool adapter that implements Transactor
+func NewPGXPool(pool *pgxpool.Pool) *PGXPool {
+	return &PGXPool{Pool: pgx4adapter.NewPool(pool), pool: pool}
+}
+
+// InTx runs fn in a new transaction
+func (a *PGXPool) InTx(ctx context.Context, fn func(tx pgAdapter.Adapter) error) error {
+	return a.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return fn(NewPGXTx(tx))
+	})
+}
+
+// PGXTx is the adapter type for PGx transaction
+type PGXTx struct {
+	tx pgx.Tx
+}
+
+// NewPGXTx instantiates PGx transaction adapter
+func NewPGXTx(tx pgx.Tx) *PGXTx {
+	return &PGXTx{tx}
+}
+
+// Exec runs a query and returns an error if any
+func (a *PGXTx) Exec(ctx context.Context, query string, args ...interface{}) error {
+	_, err := a.tx.Exec(ctx, query, args...)
+	return err
+}
+
+// SelectOne runs a select query and scans the object into a struct or returns an error
+func (a *PGXTx) SelectOne(ctx context.Context, dst interface{}, query string, args ...interface{}) error {
+	rows, err := a.tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return scanOne(rows, dst)
+}
+
+// SelectOnePrepared prepares the query under the name on the transaction connection, unless it is already there,
+// runs the prepared statement and scans the object into a struct or returns an error
+func (a *PGXTx) SelectOnePrepared(ctx context.Context, dst interface{}, name, query string, args ...interface{}) error {
+	if _, err := a.tx.Prepare(ctx, name, query); err != nil {
+		return err
+	}
+
+	rows, err := a.tx.Query(ctx, name, args...)
+	if err != nil {
+		return err
+	}
+	return scanOne(rows, dst)
+}
+
+// SelectOnePrepared prepares the query under the name on the pool connection, unless it is already there,
+// runs the prepared statement and scans the object into a struct or returns an error
+func (a *PGXPool) SelectOnePrepared(ctx context.Context, dst interface{}, name, query string, args ...interface{}) error {
+	conn, err := a.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// pgx keeps connection prepared statements, so the query is sent to the server once per connection
+	if _, err := conn.Conn().Prepare(ctx, name, query); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, name, args...)
+	if err != nil {
+		return err
+	}
+	return scanOne(rows, dst)
+}
+
+// scanOne scans the only query result row into a struct and closes the rows
+func scanOne(rows pgx.Rows, dst interface{}) error {
+	defer rows.Close()
+
+	var rowScanned int
+	err := pgxHelpers.ScanStructs(rows, func() interface{} {
+		return dst
+	}, func(r interface{}) {
+		rowScanned++
+	})
+
+	if rowScanned > 1 {
+		return pgAdapter.ErrManyRows
+	}
+
+	if rowScanned == 0 || err == pgx.ErrNoRows {
+		return pgAdapter.ErrNoRows
+	}
+
+	return err
+}
+
+// copyIdentifier returns COPY table identifier of the table name, store table names are not quoted in the queries,
+// so they are case-insensitive
+func copyIdentifier(tableName string) pgx.Identifier {
+	return pgx.Identifier(strings.Split(strings.ToLower(tableName), "."))
+}
+
+// CopyFrom loads rows into the table columns with COPY
+func (a *PGXPool) CopyFrom(ctx context.Context, tableName string, columns []string, rows [][]interface{}) (int64, error) {
+	return a.pool.CopyFrom(ctx, copyIdentifier(tableName), columns, pgx.CopyFromRows(rows))
+}
+
+// CopyFrom loads rows into the table columns with COPY
+func (a *PGXTx) CopyFrom(ctx context.Context, tableName string, columns []string, rows [][]interface{}) (int64, error) {
+	return a.tx.CopyFrom(ctx, copyIdentifier(tableName), columns, pgx.CopyFromRows(rows))
+}
@@ -0,0 +1,72 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+	"github.com/vgarvardt/go-pg-adapter/sqladapter"
+)
+
+// SQLDB is the database/sql adapter able to run store operations in a transaction
+type SQLDB struct {
+	*sqladapter.DB
+	db *sql.DB
+}
+
+// NewSQLDB instantiates database/sql adapter that implements Transactor
+func NewSQLDB(db *sql.DB) *SQLDB {
+	return &SQLDB{DB: sqladapter.New(db), db: db}
+}
+
+// InTx runs fn in a new transaction
+func (a *SQLDB) InTx(ctx context.Context, fn func(tx pgAdapter.Adapter) error) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(NewSQLTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SQLTx is the adapter type for database/sql transaction
+type SQLTx struct {
+	tx *sqlx.Tx
+}
+
+// NewSQLTx instantiates database/sql transaction adapter
+func NewSQLTx(tx *sql.Tx) *SQLTx {
+	// the same mapper sqlx uses by default
+	return &SQLTx{&sqlx.Tx{Tx: tx, Mapper: reflectx.NewMapperFunc("db", sqlx.NameMapper)}}
+}
+
+// NewSQLXTx instantiates sqlx transaction adapter
+func NewSQLXTx(tx *sqlx.Tx) *SQLTx {
+	return &SQLTx{tx}
+}
+
+// Exec runs a query and returns an error if any
+func (a *SQLTx) Exec(ctx context.Context, query string, args ...interface{}) error {
+	_, err := a.tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// SelectOne runs a select query and scans the object into a struct or returns an error
+func (a *SQLTx) SelectOne(ctx context.Context, dst interface{}, query string, args ...interface{}) error {
+	if err := a.tx.GetContext(ctx, dst, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return pgAdapter.ErrNoRows
+		}
+		return err
+	}
+
+	return nil
+}
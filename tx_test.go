@@ -0,0 +1,123 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pgAdapter "github.com/vgarvardt/go-pg-adapter"
+)
+
+func TestRunInTx(t *testing.T) {
+	ctx := context.Background()
+	adapter := new(mockAdapter)
+	tx := new(mockAdapter)
+
+	assert.Equal(t, pgAdapter.Adapter(adapter), resolveAdapter(ctx, adapter))
+	assert.Equal(t, pgAdapter.Adapter(tx), resolveAdapter(WithTransaction(ctx, tx), adapter))
+
+	// adapter that is not able to start a transaction runs the function as is
+	require.NoError(t, runInTx(ctx, adapter, func(ctx context.Context, a pgAdapter.Adapter) error {
+		assert.Equal(t, pgAdapter.Adapter(adapter), a)
+		return nil
+	}))
+
+	require.NoError(t, runInTx(WithTransaction(ctx, tx), adapter, func(ctx context.Context, a pgAdapter.Adapter) error {
+		assert.Equal(t, pgAdapter.Adapter(tx), a)
+		return nil
+	}))
+}
+
+func runTxTest(t *testing.T, store *TokenStore, begin func() (pgAdapter.Adapter, func() error, func() error)) {
+	ctx := context.Background()
+
+	newToken := func() *models.Token {
+		token := models.NewToken()
+		token.SetAccess(fmt.Sprintf("access %s", time.Now().String()))
+		token.SetAccessCreateAt(time.Now())
+		token.SetAccessExpiresIn(time.Minute)
+		return token
+	}
+
+	// rolled back with the transaction
+	tx, _, rollback := begin()
+	rolledBack := newToken()
+	require.NoError(t, store.WithTx(tx).Create(ctx, rolledBack))
+	_, err := store.WithTx(tx).GetByAccess(ctx, rolledBack.GetAccess())
+	require.NoError(t, err)
+	require.NoError(t, rollback())
+
+	_, err = store.GetByAccess(ctx, rolledBack.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+
+	// committed with the transaction bound to the context
+	tx, commit, _ := begin()
+	committed := newToken()
+	require.NoError(t, store.Create(WithTransaction(ctx, tx), committed))
+	_, err = store.GetByAccess(ctx, committed.GetAccess())
+	assert.Equal(t, pgAdapter.ErrNoRows, err)
+	require.NoError(t, commit())
+
+	_, err = store.GetByAccess(ctx, committed.GetAccess())
+	require.NoError(t, err)
+}
+
+func TestTokenStore_PGXTx(t *testing.T) {
+	pool, err := pgxpool.Connect(context.Background(), uri)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter := NewPGXPool(pool)
+	store, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	runTxTest(t, store, func() (pgAdapter.Adapter, func() error, func() error) {
+		tx, err := pool.Begin(context.Background())
+		require.NoError(t, err)
+		return NewPGXTx(tx), func() error { return tx.Commit(context.Background()) }, func() error { return tx.Rollback(context.Background()) }
+	})
+
+	errFailed := errors.New("failed")
+	assert.Equal(t, errFailed, adapter.InTx(context.Background(), func(tx pgAdapter.Adapter) error {
+		require.NoError(t, tx.Exec(context.Background(), fmt.Sprintf("DELETE FROM %s", store.tableName)))
+		return errFailed
+	}))
+}
+
+func TestTokenStore_SQLTx(t *testing.T) {
+	conn, err := sql.Open("pgx", uri)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	adapter := NewSQLDB(conn)
+	store, err := NewTokenStore(adapter, WithTokenStoreTableName(generateTokenTableName()), WithTokenStoreGCDisabled())
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	runTxTest(t, store, func() (pgAdapter.Adapter, func() error, func() error) {
+		tx, err := conn.Begin()
+		require.NoError(t, err)
+		return NewSQLTx(tx), tx.Commit, tx.Rollback
+	})
+
+	var count struct {
+		Count int64 `db:"count"`
+	}
+	require.NoError(t, adapter.InTx(context.Background(), func(tx pgAdapter.Adapter) error {
+		return tx.SelectOne(context.Background(), &count, fmt.Sprintf("SELECT count(*) AS count FROM %s", store.tableName))
+	}))
+	assert.Equal(t, int64(1), count.Count)
+}